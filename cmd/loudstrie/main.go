@@ -0,0 +1,126 @@
+/*
+Command loudstrie inspects serialized LOUDS Tries.
+
+Usage:
+
+	loudstrie diff [-values] OLD NEW
+
+diff reports keys that are added to or removed from NEW compared to OLD.
+If -values is given, OLD and NEW are serialized loudstrie.Map with string values, and keys whose values are changed are reported too.
+
+Each line of the output reports one key in lexicographic order.
+Added keys are prefixed with '+', removed keys with '-', and keys whose values are changed with '~' followed by the old and new values.
+Keys and values are quoted as Go string literals.
+
+The exit status is 0 if there are no differences, 1 if there are differences, and 2 on error.
+*/
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/hideo55/go-loudstrie"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return 2
+	}
+	switch args[0] {
+	case "diff":
+		return runDiff(args[1:], stdout, stderr)
+	}
+	usage(stderr)
+	return 2
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: loudstrie diff [-values] OLD NEW")
+}
+
+func runDiff(args []string, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	values := flags.Bool("values", false, "compare values of serialized loudstrie.Map with string values")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() != 2 {
+		usage(stderr)
+		return 2
+	}
+	oldData, err := os.ReadFile(flags.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+	newData, err := os.ReadFile(flags.Arg(1))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 2
+	}
+
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	found := false
+	if *values {
+		oldMap, err := loudstrie.NewMapFromBinary(oldData, loudstrie.StringCodec{})
+		if err != nil {
+			fmt.Fprintln(stderr, flags.Arg(0)+":", err)
+			return 2
+		}
+		newMap, err := loudstrie.NewMapFromBinary(newData, loudstrie.StringCodec{})
+		if err != nil {
+			fmt.Fprintln(stderr, flags.Arg(1)+":", err)
+			return 2
+		}
+		equal := func(x string, y string) bool { return x == y }
+		for entry := range loudstrie.DiffMaps(oldMap, newMap, equal) {
+			found = true
+			if entry.Kind == loudstrie.DiffChanged {
+				oldValue, _ := oldMap.GetByID(entry.OldID)
+				newValue, _ := newMap.GetByID(entry.NewID)
+				fmt.Fprintf(w, "~ %q %q -> %q\n", entry.Key, oldValue, newValue)
+				continue
+			}
+			printEntry(w, entry)
+		}
+	} else {
+		oldTrie, err := loudstrie.NewTrieFromBinary(oldData)
+		if err != nil {
+			fmt.Fprintln(stderr, flags.Arg(0)+":", err)
+			return 2
+		}
+		newTrie, err := loudstrie.NewTrieFromBinary(newData)
+		if err != nil {
+			fmt.Fprintln(stderr, flags.Arg(1)+":", err)
+			return 2
+		}
+		for entry := range loudstrie.Diff(oldTrie, newTrie) {
+			found = true
+			printEntry(w, entry)
+		}
+	}
+	if found {
+		return 1
+	}
+	return 0
+}
+
+func printEntry(w io.Writer, entry loudstrie.DiffEntry) {
+	switch entry.Kind {
+	case loudstrie.DiffAdded:
+		fmt.Fprintf(w, "+ %q\n", entry.Key)
+	case loudstrie.DiffRemoved:
+		fmt.Fprintf(w, "- %q\n", entry.Key)
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hideo55/go-loudstrie"
+)
+
+func writeTrie(t *testing.T, dir string, name string, keyList []string) string {
+	trie, _ := loudstrie.NewTrie(keyList, true)
+	bin, _ := trie.MarshalBinary()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, bin, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func writeMap(t *testing.T, dir string, name string, valueMap map[string]string) string {
+	m, _ := loudstrie.NewMap(valueMap, false, loudstrie.StringCodec{})
+	bin, _ := m.MarshalBinary()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, bin, 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDiff(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := writeTrie(t, dir, "old", []string{"abc", "bbc"})
+	newPath := writeTrie(t, dir, "new", []string{"abc", "can"})
+
+	var stdout, stderr bytes.Buffer
+	if status := run([]string{"diff", oldPath, newPath}, &stdout, &stderr); status != 1 {
+		t.Error("Expected 1 got", status, stderr.String())
+	}
+	if stdout.String() != "- \"bbc\"\n+ \"can\"\n" {
+		t.Error("Unexpected output", stdout.String())
+	}
+
+	stdout.Reset()
+	if status := run([]string{"diff", oldPath, oldPath}, &stdout, &stderr); status != 0 || stdout.Len() != 0 {
+		t.Error("Expected no differences", status, stdout.String())
+	}
+
+	oldMapPath := writeMap(t, dir, "oldmap", map[string]string{"a": "1", "b": "2"})
+	newMapPath := writeMap(t, dir, "newmap", map[string]string{"a": "10", "b": "2"})
+	stdout.Reset()
+	if status := run([]string{"diff", "-values", oldMapPath, newMapPath}, &stdout, &stderr); status != 1 {
+		t.Error("Expected 1 got", status, stderr.String())
+	}
+	if stdout.String() != "~ \"a\" \"1\" -> \"10\"\n" {
+		t.Error("Unexpected output", stdout.String())
+	}
+
+	if status := run([]string{"diff", oldPath, filepath.Join(dir, "missing")}, &stdout, &stderr); status != 2 {
+		t.Error("Expected 2 got", status)
+	}
+	if status := run([]string{"unknown"}, &stdout, &stderr); status != 2 {
+		t.Error("Expected 2 got", status)
+	}
+}
@@ -0,0 +1,8 @@
+module github.com/hideo55/go-loudstrie
+
+go 1.23
+
+require (
+	github.com/hideo55/go-sbvector master
+	github.com/oleiade/lane v1.0.1
+)
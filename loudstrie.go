@@ -51,9 +51,10 @@ package loudstrie
 import (
 	"bytes"
 	"encoding"
-	"encoding/binary"
 	"errors"
-	"unsafe"
+	"io"
+	"slices"
+	"unicode/utf8"
 
 	"github.com/hideo55/go-sbvector"
 )
@@ -72,6 +73,21 @@ type TrieData struct {
 	tailTrie    Trie
 	tailIDs     sbvector.SuccinctBitVector
 	tailIDSize  uint64
+	// runeReverse is true if tails in tailTrie are reversed by runes instead of bytes.
+	runeReverse bool
+	hasWeights  bool
+	weights     sbvector.SuccinctBitVector
+	maxWeights  sbvector.SuccinctBitVector
+	weightSize  uint64
+	hasLexID    bool
+	lexBase     sbvector.SuccinctBitVector
+	lexRanks    sbvector.SuccinctBitVector
+	lexIDSize   uint64
+	// tombstones is bit vector of deleted keys indexed by rank in terminal.
+	tombstones   []uint64
+	numOfDeleted uint64
+	// deletedLexIDs holds lexicographic IDs of deleted keys in ascending order.
+	deletedLexIDs []uint64
 }
 
 /*
@@ -84,6 +100,24 @@ type Result struct {
 	Length uint64
 }
 
+/*
+KeyID holds key string and ID of the key.
+*/
+type KeyID struct {
+	// Key string.
+	Key string
+	// ID of the key.
+	ID uint64
+}
+
+/*
+keyBytes is constraint of key types of the queries.
+Queries are implemented once for string and []byte, so that []byte keys are searched without conversion.
+*/
+type keyBytes interface {
+	~string | ~[]byte
+}
+
 /*
 Trie is interface of LOUDS Trie.
 */
@@ -106,11 +140,28 @@ const (
 
 	sizeOfInt32 uint32 = 4
 	sizeOfInt64 uint32 = 8
+
+	// sectionWeights is tag of the section that holds weights of the keys.
+	sectionWeights uint32 = 1
+	// sectionLexicographicID is tag of the section that holds lexicographic IDs.
+	sectionLexicographicID uint32 = 2
+	// sectionTombstones is tag of the section that holds deleted keys.
+	sectionTombstones uint32 = 3
 )
 
 var (
 	// ErrorInvalidFormat indicates that binary format is invalid.
 	ErrorInvalidFormat = errors.New("UnmarshalBinary: invalid binary format")
+	// ErrorTooLarge indicates that size of a section doesn't fit the binary format.
+	ErrorTooLarge = errors.New("MarshalBinary: section is too large for the binary format")
+	// ErrorNotLoudsTrie indicates that binary data isn't written by this package. errors.Is(ErrorNotLoudsTrie, ErrorInvalidFormat) is true.
+	ErrorNotLoudsTrie error = &formatError{"UnmarshalBinary: not a loudstrie binary"}
+	// ErrorUnsupportedVersion indicates that format version or features of binary data aren't supported. errors.Is(ErrorUnsupportedVersion, ErrorInvalidFormat) is true.
+	ErrorUnsupportedVersion error = &formatError{"UnmarshalBinary: unsupported format version"}
+	// ErrorChecksumMismatch indicates that binary data is corrupted. errors.Is(ErrorChecksumMismatch, ErrorInvalidFormat) is true.
+	ErrorChecksumMismatch error = &formatError{"UnmarshalBinary: checksum mismatch"}
+	// ErrorInvalidWeights indicates that number of weights does not match number of keys.
+	ErrorInvalidWeights = errors.New("NewTrieWithWeights: number of weights does not match number of keys")
 )
 
 /*
@@ -129,12 +180,25 @@ This function returns id of the exact matched key with query string.
 If couldn't find exact matched key, value of second result parameter is false.
 */
 func (trie *TrieData) ExactMatchSearch(key string) (uint64, bool) {
+	return exactMatchSearch(trie, key)
+}
+
+/*
+ExactMatchSearchBytes looks up exact match key with query bytes.
+
+This function is same as ExactMatchSearch, but the query isn't converted to string, so it doesn't allocate.
+*/
+func (trie *TrieData) ExactMatchSearchBytes(key []byte) (uint64, bool) {
+	return exactMatchSearch(trie, key)
+}
+
+func exactMatchSearch[K keyBytes](trie *TrieData, key K) (uint64, bool) {
 	nodePos := uint64(0)
 	zeros := uint64(0)
 	keyPos := uint64(0)
 	keyLen := uint64(len(key))
 	for keyPos <= keyLen {
-		id, canTraverse := trie.Traverse(key, keyLen, &nodePos, &zeros, &keyPos)
+		id, canTraverse := traverse(trie, key, keyLen, &nodePos, &zeros, &keyPos)
 		if keyPos == keyLen+1 && id != NotFound {
 			return id, true
 		}
@@ -151,6 +215,19 @@ CommonPrefixSearch looks up keys from the possible prefixes of a query string.
 This function returns slice of `Result`. `Result` holds ID and length of the key.
 */
 func (trie *TrieData) CommonPrefixSearch(key string, limit uint64) []Result {
+	return commonPrefixSearch(trie, key, limit)
+}
+
+/*
+CommonPrefixSearchBytes looks up keys from the possible prefixes of query bytes.
+
+This function is same as CommonPrefixSearch, but the query isn't converted to string.
+*/
+func (trie *TrieData) CommonPrefixSearchBytes(key []byte, limit uint64) []Result {
+	return commonPrefixSearch(trie, key, limit)
+}
+
+func commonPrefixSearch[K keyBytes](trie *TrieData, key K, limit uint64) []Result {
 	nodePos := uint64(0)
 	zeros := uint64(0)
 	keyPos := uint64(0)
@@ -161,7 +238,7 @@ func (trie *TrieData) CommonPrefixSearch(key string, limit uint64) []Result {
 	}
 
 	for {
-		id, canTraverse := trie.Traverse(key, keyLen, &nodePos, &zeros, &keyPos)
+		id, canTraverse := traverse(trie, key, keyLen, &nodePos, &zeros, &keyPos)
 		if id != NotFound {
 			res = append(res, Result{id, keyPos - 1})
 			if uint64(len(res)) == limit {
@@ -180,10 +257,59 @@ PredictiveSearch searches keys starting with a query string.
 This function returns slice of ID.
 */
 func (trie *TrieData) PredictiveSearch(key string, limit uint64) []uint64 {
+	return predictiveSearch(trie, key, limit)
+}
+
+/*
+PredictiveSearchBytes searches keys starting with query bytes.
+
+This function is same as PredictiveSearch, but the query isn't converted to string.
+*/
+func (trie *TrieData) PredictiveSearchBytes(key []byte, limit uint64) []uint64 {
+	return predictiveSearch(trie, key, limit)
+}
+
+func predictiveSearch[K keyBytes](trie *TrieData, key K, limit uint64) []uint64 {
 	var res []uint64
 	if limit == 0 {
 		limit = noLimit
 	}
+	pos, zeros, _, found := searchPrefix(trie, key)
+	if !found {
+		return res
+	}
+	trie.enumerateAll(pos, zeros, &res, limit)
+	return res
+}
+
+/*
+PredictiveSearchKeys searches keys starting with a query string.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+
+Keys are reconstructed while enumerating the subtree, so this function is faster than calling DecodeKey for each result of PredictiveSearch.
+*/
+func (trie *TrieData) PredictiveSearchKeys(key string, limit uint64) []KeyID {
+	var res []KeyID
+	if limit == 0 {
+		limit = noLimit
+	}
+	pos, zeros, depth, found := searchPrefix(trie, key)
+	if !found {
+		return res
+	}
+	trie.walkKeys(pos, zeros, []byte(key[:depth]), func(id uint64, key []byte) bool {
+		res = append(res, KeyID{string(key), id})
+		return uint64(len(res)) < limit
+	})
+	return res
+}
+
+/*
+searchPrefix looks up the node that is root of the subtree holding keys starting with a query string.
+If the query string ends inside a TAIL, the node holding the TAIL is returned.
+Third result parameter is depth of the node.
+*/
+func searchPrefix[K keyBytes](trie *TrieData, key K) (uint64, uint64, uint64, bool) {
 	pos := uint64(2)
 	zeros := uint64(2)
 	keyLen := uint64(len(key))
@@ -191,23 +317,17 @@ func (trie *TrieData) PredictiveSearch(key string, limit uint64) []uint64 {
 		ones := pos - zeros
 		if ok, _ := trie.tail.Get(ones); ok {
 			tailID, _ := trie.tail.Rank1(ones)
-			tail := trie.getTail(tailID)
-			for j := i; j < keyLen; j++ {
-				if key[j] != tail[j-i] {
-					return res
-				}
+			if !tailHasPrefix(trie, tailID, key[i:]) {
+				return NotFound, NotFound, 0, false
 			}
-			id, _ := trie.terminal.Rank1(ones)
-			res = append(res, id)
-			return res
+			return pos, zeros, i, true
 		}
 		trie.getChild(key[i], &pos, &zeros)
 		if pos == NotFound {
-			return res
+			return NotFound, NotFound, 0, false
 		}
 	}
-	trie.enumerateAll(pos, zeros, &res, limit)
-	return res
+	return pos, zeros, keyLen, true
 }
 
 /*
@@ -220,6 +340,10 @@ If current node is not "terminal", value of ID(first result parameter) is `louds
 If value of the second result parameter's  is false , it indicates "Can't transition to next node".
 */
 func (trie *TrieData) Traverse(key string, keyLen uint64, nodePos *uint64, zeros *uint64, keyPos *uint64) (uint64, bool) {
+	return traverse(trie, key, keyLen, nodePos, zeros, keyPos)
+}
+
+func traverse[K keyBytes](trie *TrieData, key K, keyLen uint64, nodePos *uint64, zeros *uint64, keyPos *uint64) (uint64, bool) {
 	id := NotFound
 	if *nodePos == NotFound {
 		return id, false
@@ -232,12 +356,12 @@ func (trie *TrieData) Traverse(key string, keyLen uint64, nodePos *uint64, zeros
 	if hasTail {
 		retLen := uint64(0)
 		tailRank, _ := trie.tail.Rank1(ones)
-		if trie.tailMatch(key, keyLen, *keyPos, tailRank, &retLen) {
+		if tailMatch(trie, key, keyLen, *keyPos, tailRank, &retLen) {
 			*keyPos += retLen
-			id, _ = trie.terminal.Rank1(ones)
+			id, _ = trie.terminalID(ones)
 		}
-	} else if ok, _ := trie.terminal.Get(ones); ok {
-		id, _ = trie.terminal.Rank1(ones)
+	} else {
+		id, _ = trie.terminalID(ones)
 	}
 	if *keyPos < keyLen {
 		trie.getChild(key[*keyPos], nodePos, zeros)
@@ -254,8 +378,28 @@ func (trie *TrieData) Traverse(key string, keyLen uint64, nodePos *uint64, zeros
 }
 
 func (trie *TrieData) isLeaf(pos uint64) bool {
-	val, _ := trie.louds.Get(pos)
-	return val
+	val, err := trie.louds.Get(pos)
+	return val || err != nil
+}
+
+func (trie *TrieData) getNode(nodeID uint64) (uint64, uint64) {
+	pos, _ := trie.louds.Select1(nodeID)
+	pos++
+	return pos, pos - nodeID
+}
+
+func (trie *TrieData) terminalID(nodeID uint64) (uint64, bool) {
+	if ok, _ := trie.terminal.Get(nodeID); !ok {
+		return NotFound, false
+	}
+	id, _ := trie.terminal.Rank1(nodeID)
+	if trie.isDeleted(id) {
+		return NotFound, false
+	}
+	if trie.hasLexID {
+		id, _ = trie.lexBase.GetBits(trie.lexIDSize*nodeID, trie.lexIDSize)
+	}
+	return id, true
 }
 
 func (trie *TrieData) getParent(c *byte, pos *uint64, zeros *uint64) {
@@ -290,13 +434,11 @@ func (trie *TrieData) getChild(c byte, pos *uint64, zeros *uint64) {
 
 func (trie *TrieData) enumerateAll(pos uint64, zeros uint64, res *[]uint64, limit uint64) {
 	ones := pos - zeros
-	term, _ := trie.terminal.Get(ones)
-	if term {
-		rank, _ := trie.terminal.Rank1(ones)
-		*res = append(*res, rank)
+	if id, found := trie.terminalID(ones); found {
+		*res = append(*res, id)
 	}
 	for i := uint64(0); uint64(len(*res)) < limit; i++ {
-		if ok, _ := trie.louds.Get(pos + i); ok {
+		if trie.isLeaf(pos + i) {
 			break
 		}
 		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
@@ -305,320 +447,571 @@ func (trie *TrieData) enumerateAll(pos uint64, zeros uint64, res *[]uint64, limi
 	}
 }
 
-func (trie *TrieData) tailMatch(str string, strlen uint64, depth uint64, tailID uint64, retLen *uint64) bool {
-	tail := trie.getTail(tailID)
-	tailLen := uint64(len(tail))
-	if tailLen > (strlen - depth) {
+func tailMatch[K keyBytes](trie *TrieData, str K, strlen uint64, depth uint64, tailID uint64, retLen *uint64) bool {
+	if !trie.hasTailTrie {
+		return matchPrefix(str[depth:strlen], trie.vtails[tailID], retLen)
+	}
+	var buf [64]byte
+	return matchPrefix(str[depth:strlen], trie.appendTail(buf[:0], tailID), retLen)
+}
+
+// tailHasPrefix returns true if the TAIL starts with prefix.
+func tailHasPrefix[K keyBytes](trie *TrieData, tailID uint64, prefix K) bool {
+	retLen := uint64(0)
+	if !trie.hasTailTrie {
+		return matchPrefix(trie.vtails[tailID], prefix, &retLen)
+	}
+	var buf [64]byte
+	return matchPrefix(trie.appendTail(buf[:0], tailID), prefix, &retLen)
+}
+
+// matchPrefix returns true if str starts with prefix, and sets length of prefix to retLen.
+func matchPrefix[K keyBytes, P keyBytes](str K, prefix P, retLen *uint64) bool {
+	prefixLen := uint64(len(prefix))
+	if prefixLen > uint64(len(str)) {
 		return false
 	}
-	for i := uint64(0); i < tailLen; i++ {
-		if str[i+depth] != tail[i] {
+	for i := uint64(0); i < prefixLen; i++ {
+		if str[i] != prefix[i] {
 			return false
 		}
 	}
-	*retLen = tailLen
+	*retLen = prefixLen
 	return true
 }
 
+// commonPrefixLen returns length of the common prefix of a and b.
+func commonPrefixLen[A keyBytes, B keyBytes](a A, b B) uint64 {
+	n := uint64(0)
+	for n < uint64(len(a)) && n < uint64(len(b)) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
 func (trie *TrieData) getTail(tailID uint64) string {
 	if trie.hasTailTrie {
-		id, _ := trie.tailIDs.GetBits(trie.tailIDSize*tailID, trie.tailIDSize)
+		return string(trie.appendTail(nil, tailID))
+	}
+	return trie.vtails[tailID]
+}
+
+// appendTail appends the TAIL to dst. TAIL trie holds reversed TAILs, so the appended bytes are reversed again.
+func (trie *TrieData) appendTail(dst []byte, tailID uint64) []byte {
+	if !trie.hasTailTrie {
+		return append(dst, trie.vtails[tailID]...)
+	}
+	id, _ := trie.tailIDs.GetBits(trie.tailIDSize*tailID, trie.tailIDSize)
+	tailTrie := trie.tailTrie.(*TrieData)
+	if trie.runeReverse || tailTrie.hasTailTrie {
+		// TAIL tries built by this package don't have TAIL trie, so nested one is decoded by DecodeKey.
+		// Calling AppendKey of TAIL trie recursively would make dst escape to heap.
 		tail, _ := trie.tailTrie.DecodeKey(id)
-		runes := []rune(tail)
-		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-			runes[i], runes[j] = runes[j], runes[i]
+		if trie.runeReverse {
+			return append(dst, reverseRunes(tail)...)
 		}
-		return string(runes)
+		return append(dst, reverseString(tail)...)
 	}
-	return trie.vtails[tailID]
+	nodeID, found := tailTrie.keyNode(id)
+	if !found {
+		return dst
+	}
+	start := len(dst)
+	dst = tailTrie.appendPath(dst, nodeID)
+	if hasTail, _ := tailTrie.tail.Get(nodeID); hasTail {
+		rank, _ := tailTrie.tail.Rank1(nodeID)
+		dst = append(dst, tailTrie.vtails[rank]...)
+	}
+	reverseBytes(dst[start:])
+	return dst
 }
 
 /*
 DecodeKey returns key string corresponding to the ID.
 */
 func (trie *TrieData) DecodeKey(id uint64) (string, bool) {
-	if trie.terminal.NumOfBits(true) < id {
+	key, found := trie.AppendKey(nil, id)
+	if !found {
 		return "", false
 	}
-	nodeID, _ := trie.terminal.Select1(id)
+	return string(key), true
+}
+
+/*
+AppendKey appends key corresponding to the ID to dst, and returns the extended buffer.
+
+If the ID isn't found, dst is returned as is and value of second result parameter is false.
+Reusing dst, keys are decoded without allocation.
+*/
+func (trie *TrieData) AppendKey(dst []byte, id uint64) ([]byte, bool) {
+	nodeID, found := trie.keyNode(id)
+	if !found {
+		return dst, false
+	}
+	dst = trie.appendPath(dst, nodeID)
+	hasTail, _ := trie.tail.Get(nodeID)
+	if hasTail {
+		rank, _ := trie.tail.Rank1(nodeID)
+		dst = trie.appendTail(dst, rank)
+	}
+
+	return dst, true
+}
+
+// keyNode returns ID of the terminal node of the key.
+func (trie *TrieData) keyNode(id uint64) (uint64, bool) {
+	if trie.terminal.NumOfBits(true) <= id {
+		return 0, false
+	}
+	rank := id
+	if trie.hasLexID {
+		rank, _ = trie.lexRanks.GetBits(trie.lexIDSize*id, trie.lexIDSize)
+	}
+	if trie.isDeleted(rank) {
+		return 0, false
+	}
+	nodeID, _ := trie.terminal.Select1(rank)
+	return nodeID, true
+}
+
+// appendPath appends labels of the edges from the root to the node to dst.
+func (trie *TrieData) appendPath(dst []byte, nodeID uint64) []byte {
 	pos, _ := trie.louds.Select1(nodeID)
 	pos++
 	zeros := pos - nodeID
-	var keyBuf []byte
+	// Labels are appended from the node to the root, and reversed.
+	start := len(dst)
 	for {
 		c := byte(0)
 		trie.getParent(&c, &pos, &zeros)
 		if pos == 0 {
 			break
 		}
-		keyBuf = append([]byte{c}, keyBuf...)
+		dst = append(dst, c)
 	}
-	key := bytes.NewBuffer(keyBuf).String()
-	hasTail, _ := trie.tail.Get(nodeID)
-	if hasTail {
-		rank, _ := trie.tail.Rank1(nodeID)
-		tailStr := trie.getTail(rank)
-		key += tailStr
+	reverseBytes(dst[start:])
+	return dst
+}
+
+/*
+Rank returns number of keys that are less than the query string in lexicographic order.
+Deleted keys aren't counted.
+
+If the trie isn't built with LexicographicID option, value of second result parameter is false.
+*/
+func (trie *TrieData) Rank(key string) (uint64, bool) {
+	if !trie.hasLexID {
+		return 0, false
+	}
+	id := trie.lexLowerBound(key)
+	if trie.numOfDeleted == 0 {
+		return id, true
 	}
+	numOfDeleted, _ := slices.BinarySearch(trie.deletedLexIDs, id)
+	return id - uint64(numOfDeleted), true
+}
 
-	return key, true
+// lexLowerBound returns the smallest lexicographic ID of the keys that are greater than or equal to the query string, including deleted keys.
+func (trie *TrieData) lexLowerBound(key string) uint64 {
+	if trie.numOfKeys == 0 {
+		return 0
+	}
+	pos := uint64(2)
+	zeros := uint64(2)
+	keyLen := uint64(len(key))
+	for i := uint64(0); ; i++ {
+		ones := pos - zeros
+		if ok, _ := trie.tail.Get(ones); ok {
+			// The node holds only one key that is key[:i] + TAIL.
+			tailID, _ := trie.tail.Rank1(ones)
+			if key[i:] <= trie.getTail(tailID) {
+				return trie.getLexBase(ones)
+			}
+			return trie.getLexBase(ones) + uint64(1)
+		}
+		if i == keyLen {
+			return trie.getLexBase(ones)
+		}
+		found := false
+		for j := uint64(0); !trie.isLeaf(pos + j); j++ {
+			edge := trie.edges[zeros+j-uint64(2)]
+			if edge < key[i] {
+				continue
+			}
+			nextPos, _ := trie.louds.Select1(zeros + j - uint64(1))
+			nextPos++
+			nextZeros := nextPos - zeros - j + uint64(1)
+			if edge > key[i] {
+				return trie.getLexBase(nextPos - nextZeros)
+			}
+			pos = nextPos
+			zeros = nextZeros
+			found = true
+			break
+		}
+		if !found {
+			return trie.getLexUpperBound(pos, zeros)
+		}
+	}
 }
 
 /*
-GetNumOfKeys returns number of keys in trie.
+Select returns key string that is i-th smallest in lexicographic order.
+Deleted keys are skipped, so Select(Rank(key)) returns the key.
+
+If the trie isn't built with LexicographicID option, value of second result parameter is false.
+*/
+func (trie *TrieData) Select(i uint64) (string, bool) {
+	if !trie.hasLexID {
+		return "", false
+	}
+	id := i
+	if trie.numOfDeleted != 0 {
+		for _, deleted := range trie.deletedLexIDs {
+			if deleted > id {
+				break
+			}
+			id++
+		}
+	}
+	return trie.DecodeKey(id)
+}
+
+func (trie *TrieData) getLexBase(nodeID uint64) uint64 {
+	id, _ := trie.lexBase.GetBits(trie.lexIDSize*nodeID, trie.lexIDSize)
+	return id
+}
+
+// getLexUpperBound returns the ID that follows the largest ID in the subtree of the node.
+func (trie *TrieData) getLexUpperBound(pos uint64, zeros uint64) uint64 {
+	for {
+		numOfChildren := uint64(0)
+		for !trie.isLeaf(pos + numOfChildren) {
+			numOfChildren++
+		}
+		if numOfChildren == 0 {
+			return trie.getLexBase(pos-zeros) + uint64(1)
+		}
+		lastChild := zeros + numOfChildren - uint64(2)
+		pos, _ = trie.louds.Select1(lastChild)
+		pos++
+		zeros = pos - lastChild
+	}
+}
+
+/*
+GetNumOfKeys returns number of keys in trie. Deleted keys aren't counted.
 */
 func (trie *TrieData) GetNumOfKeys() uint64 {
-	return trie.numOfKeys
+	return trie.numOfKeys - trie.numOfDeleted
 }
 
 /*
 MarshalBinary implements the encoding.BinaryMarshaler interface.
+Binary data is written in format version 2, whose sections have 64-bit sizes.
 */
 func (trie *TrieData) MarshalBinary() ([]byte, error) {
 	buffer := new(bytes.Buffer)
+	if _, err := trie.WriteTo(buffer); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
+/*
+MarshalBinaryLegacy returns binary data of the legacy format, which has no header and no checksum.
+Use this function only to pass the trie to older versions of this package that can't read format version 2.
+Sizes of sections are 32-bit in the legacy format, so ErrorTooLarge is returned if a section is 4 GiB or larger.
+*/
+func (trie *TrieData) MarshalBinaryLegacy() ([]byte, error) {
+	buffer := new(bytes.Buffer)
+	writer := &binaryWriter{w: buffer}
+	trie.writeLegacy(writer)
+	if writer.err != nil {
+		return nil, writer.err
+	}
+	return buffer.Bytes(), nil
+}
 
-	binary.Write(buffer, binary.LittleEndian, &trie.numOfKeys)
+/*
+WriteTo implements the io.WriterTo interface.
+
+WriteTo writes same binary data as MarshalBinary. Sections are serialized in memory before they are written, because the section table at the head holds their sizes and checksums.
+*/
+func (trie *TrieData) WriteTo(w io.Writer) (int64, error) {
+	writer := &binaryWriter{w: w}
+	trie.writeFormat(writer)
+	return writer.n, writer.err
+}
+
+// writeLegacy writes binary data of the legacy format.
+func (trie *TrieData) writeLegacy(writer *binaryWriter) {
+	writer.writeUint64(trie.numOfKeys)
 
 	// louds
-	buf, _ := trie.louds.MarshalBinary()
-	loudsSize := uint32(len(buf))
-	binary.Write(buffer, binary.LittleEndian, &loudsSize)
-	binary.Write(buffer, binary.LittleEndian, buf)
+	writer.writeVector(trie.louds)
 
 	// terminal
-	buf, _ = trie.terminal.MarshalBinary()
-	terminalSize := uint32(len(buf))
-	binary.Write(buffer, binary.LittleEndian, &terminalSize)
-	binary.Write(buffer, binary.LittleEndian, buf)
+	writer.writeVector(trie.terminal)
 
 	// tail
-	buf, _ = trie.tail.MarshalBinary()
-	tailSize := uint32(len(buf))
-	binary.Write(buffer, binary.LittleEndian, &tailSize)
-	binary.Write(buffer, binary.LittleEndian, buf)
+	writer.writeVector(trie.tail)
 
 	// edges
-	edgesSize := uint32(len(trie.edges))
-	binary.Write(buffer, binary.LittleEndian, &edgesSize)
-	binary.Write(buffer, binary.LittleEndian, trie.edges)
+	writer.writeSize32(uint64(len(trie.edges)))
+	writer.write(trie.edges)
 
 	// hasTailTrie
+	// Older versions reverse tails in tailTrie by runes, so tails are written without tailTrie if they have non-ASCII bytes.
+	useTailTrie := trie.hasTailTrie && (trie.runeReverse || trie.isASCIITails())
 	hasTailTrie := uint32(0)
-	if trie.hasTailTrie {
+	if useTailTrie {
 		hasTailTrie = uint32(1)
 	}
-	binary.Write(buffer, binary.LittleEndian, &hasTailTrie)
+	writer.writeUint32(hasTailTrie)
 
-	if trie.hasTailTrie {
+	if useTailTrie {
 		// tailTrie
-		buf, _ = trie.tailTrie.MarshalBinary()
-		tailTrieSize := uint32(len(buf))
-		binary.Write(buffer, binary.LittleEndian, &tailTrieSize)
-		binary.Write(buffer, binary.LittleEndian, buf)
+		tailTrie := trie.tailTrie.(*TrieData)
+		// Size of tailTrie is counted by writing it to io.Discard before writing it.
+		counter := &binaryWriter{w: io.Discard}
+		tailTrie.writeLegacy(counter)
+		if counter.err != nil {
+			writer.fail(counter.err)
+			return
+		}
+		writer.writeSize32(uint64(counter.n))
+		tailTrie.writeLegacy(writer)
 
 		// tailIDSize
-		binary.Write(buffer, binary.LittleEndian, &trie.tailIDSize)
+		writer.writeUint64(trie.tailIDSize)
 
 		// tailIDs
-		buf, _ = trie.tailIDs.MarshalBinary()
-		tailIDsSize := uint32(len(buf))
-		binary.Write(buffer, binary.LittleEndian, &tailIDsSize)
-		binary.Write(buffer, binary.LittleEndian, buf)
-
+		writer.writeVector(trie.tailIDs)
 	} else {
-		vtailSize := uint32(len(trie.vtails))
-		binary.Write(buffer, binary.LittleEndian, &vtailSize)
-		for _, str := range trie.vtails {
-			buf = *(*[]byte)(unsafe.Pointer(&str))
-			strlen := uint32(len(buf))
-			binary.Write(buffer, binary.LittleEndian, &strlen)
-			binary.Write(buffer, binary.LittleEndian, buf)
+		numOfTails := trie.tail.NumOfBits(true)
+		writer.writeSize32(numOfTails)
+		for tailID := uint64(0); tailID < numOfTails; tailID++ {
+			str := trie.getTail(tailID)
+			writer.writeSize32(uint64(len(str)))
+			writer.writeString(str)
 		}
 	}
-	return buffer.Bytes(), nil
+
+	// Optional sections follow. Each section starts with tag and size of the payload.
+	if trie.hasWeights {
+		section := new(bytes.Buffer)
+		sectionWriter := &binaryWriter{w: section}
+		sectionWriter.writeUint64(trie.weightSize)
+		sectionWriter.writeVector(trie.weights)
+		sectionWriter.writeVector(trie.maxWeights)
+		if sectionWriter.err != nil {
+			writer.fail(sectionWriter.err)
+			return
+		}
+		writer.writeSection(sectionWeights, section.Bytes())
+	}
+	if trie.hasLexID {
+		section := new(bytes.Buffer)
+		sectionWriter := &binaryWriter{w: section}
+		sectionWriter.writeUint64(trie.lexIDSize)
+		sectionWriter.writeVector(trie.lexBase)
+		sectionWriter.writeVector(trie.lexRanks)
+		if sectionWriter.err != nil {
+			writer.fail(sectionWriter.err)
+			return
+		}
+		writer.writeSection(sectionLexicographicID, section.Bytes())
+	}
+	if trie.numOfDeleted != 0 {
+		buf, err := trie.marshalTombstones()
+		if err != nil {
+			writer.fail(err)
+			return
+		}
+		writer.writeSection(sectionTombstones, buf)
+	}
 }
 
 /*
 UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
 */
 func (trie *TrieData) UnmarshalBinary(data []byte) error {
-	newtrie := new(TrieData)
-	offset := uint32(0)
-
-	if uint32(len(data)) < offset+sizeOfInt64 {
-		return ErrorInvalidFormat
-	}
-	buf := data[offset : offset+sizeOfInt64]
-	offset += sizeOfInt64
-	newtrie.numOfKeys = binary.LittleEndian.Uint64(buf)
+	return trie.load(&binaryReader{inMemory: true, data: data}, LoadOptions{})
+}
 
-	if uint32(len(data)) < sizeOfInt32 {
-		return ErrorInvalidFormat
-	}
-	buf = data[offset : offset+sizeOfInt32]
-	offset += sizeOfInt32
-	loudsSize := binary.LittleEndian.Uint32(buf)
+/*
+ReadFrom implements the io.ReaderFrom interface.
 
-	if uint32(len(data)) < offset+loudsSize {
-		return ErrorInvalidFormat
-	}
-	buf = data[offset : offset+loudsSize]
-	offset += loudsSize
-	louds, err := sbvector.NewVectorFromBinary(buf)
-	if err != nil {
-		return ErrorInvalidFormat
-	}
-	newtrie.louds = louds
+ReadFrom reads binary data written by WriteTo, MarshalBinary or MarshalBinaryLegacy until EOF.
+Sections are read one by one, so whole of the binary data isn't held in memory.
+*/
+func (trie *TrieData) ReadFrom(r io.Reader) (int64, error) {
+	reader := newStreamReader(r)
+	err := trie.load(reader, LoadOptions{})
+	return reader.n, err
+}
 
-	if uint32(len(data)) < offset+sizeOfInt32 {
-		return ErrorInvalidFormat
+// readLegacy reads binary data of the legacy format.
+func (trie *TrieData) readLegacy(reader *binaryReader) error {
+	var err error
+	if trie.numOfKeys, err = reader.readUint64(); err != nil {
+		return err
 	}
-	buf = data[offset : offset+sizeOfInt32]
-	offset += sizeOfInt32
-	terminalSize := binary.LittleEndian.Uint32(buf)
 
-	if uint32(len(data)) < offset+terminalSize {
-		return ErrorInvalidFormat
+	// louds
+	if trie.louds, err = reader.readVector(); err != nil {
+		return err
 	}
 
-	buf = data[offset : offset+terminalSize]
-	terminal, err := sbvector.NewVectorFromBinary(buf)
-	if err != nil {
-		return ErrorInvalidFormat
+	// terminal
+	if trie.terminal, err = reader.readVector(); err != nil {
+		return err
 	}
-	newtrie.terminal = terminal
-	offset += terminalSize
 
-	if uint32(len(data)) < offset+sizeOfInt32 {
-		return ErrorInvalidFormat
+	// tail
+	if trie.tail, err = reader.readVector(); err != nil {
+		return err
 	}
-	buf = data[offset : offset+sizeOfInt32]
-	offset += sizeOfInt32
-	tailSize := binary.LittleEndian.Uint32(buf)
 
-	if uint32(len(data)) < offset+tailSize {
-		return ErrorInvalidFormat
-	}
-	buf = data[offset : offset+tailSize]
-	tail, err := sbvector.NewVectorFromBinary(buf)
+	// edges
+	edgesSize, err := reader.readUint32()
 	if err != nil {
-		return ErrorInvalidFormat
-	}
-	newtrie.tail = tail
-	offset += tailSize
-
-	if uint32(len(data)) < offset+sizeOfInt32 {
-		return ErrorInvalidFormat
+		return err
 	}
-	buf = data[offset : offset+sizeOfInt32]
-	offset += sizeOfInt32
-	edgesSize := binary.LittleEndian.Uint32(buf)
-
-	if uint32(len(data)) < offset+edgesSize {
-		return ErrorInvalidFormat
+	edges, err := reader.readBytes(uint64(edgesSize))
+	if err != nil {
+		return err
 	}
-	buf = data[offset : offset+edgesSize]
-	newtrie.edges = make([]byte, edgesSize)
-	copy(newtrie.edges, buf)
-	offset += edgesSize
+	trie.edges = reader.ownBytes(edges)
 
-	if uint32(len(data)) < offset+sizeOfInt32 {
-		return ErrorInvalidFormat
-	}
-	buf = data[offset : offset+sizeOfInt32]
-	offset += sizeOfInt32
-	hasTailTrie := binary.LittleEndian.Uint32(buf)
-	if hasTailTrie == 0 {
-		newtrie.hasTailTrie = false
-	} else {
-		newtrie.hasTailTrie = true
+	// hasTailTrie
+	hasTailTrie, err := reader.readUint32()
+	if err != nil {
+		return err
 	}
+	trie.hasTailTrie = hasTailTrie != 0
+	trie.runeReverse = trie.hasTailTrie
 
-	if newtrie.hasTailTrie {
-		if uint32(len(data)) < offset+sizeOfInt32 {
-			return ErrorInvalidFormat
-		}
-		buf = data[offset : offset+sizeOfInt32]
-		offset += sizeOfInt32
-		tailTrieSize := binary.LittleEndian.Uint32(buf)
-
-		if uint32(len(data)) < offset+tailTrieSize {
-			return ErrorInvalidFormat
+	if trie.hasTailTrie {
+		// tailTrie
+		tailTrieSize, err := reader.readUint32()
+		if err != nil {
+			return err
 		}
-		buf = data[offset : offset+tailTrieSize]
-		newtrie.tailTrie = &TrieData{}
-		err = newtrie.tailTrie.UnmarshalBinary(buf)
+		tailTrie := new(TrieData)
+		tailReader, err := reader.subReader(uint64(tailTrieSize))
 		if err != nil {
-			return ErrorInvalidFormat
+			return err
 		}
-		offset += tailTrieSize
-
-		if uint32(len(data)) < offset+sizeOfInt64 {
+		if err := tailTrie.readFrom(tailReader); err != nil {
+			return err
+		}
+		if tailReader.n != int64(tailTrieSize) {
 			return ErrorInvalidFormat
 		}
-		buf = data[offset : offset+sizeOfInt64]
-		offset += sizeOfInt64
-		newtrie.tailIDSize = binary.LittleEndian.Uint64(buf)
+		trie.tailTrie = tailTrie
 
-		if uint32(len(data)) < offset+sizeOfInt32 {
-			return ErrorInvalidFormat
+		// tailIDSize
+		if trie.tailIDSize, err = reader.readUint64(); err != nil {
+			return err
 		}
 
-		buf = data[offset : offset+sizeOfInt32]
-		offset += sizeOfInt32
-		tailIDsSize := binary.LittleEndian.Uint32(buf)
+		// tailIDs
+		if trie.tailIDs, err = reader.readVector(); err != nil {
+			return err
+		}
+	} else {
+		vtailSize, err := reader.readUint32()
+		if err != nil {
+			return err
+		}
+		for i := uint32(0); i < vtailSize; i++ {
+			strSize, err := reader.readUint32()
+			if err != nil {
+				return err
+			}
+			buf, err := reader.readBytes(uint64(strSize))
+			if err != nil {
+				return err
+			}
+			trie.vtails = append(trie.vtails, reader.ownString(buf))
+		}
+	}
 
-		if uint32(len(data)) < offset+tailIDsSize {
-			return ErrorInvalidFormat
+	for {
+		tag, ok, err := reader.readTag()
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
 		}
-		buf = data[offset : offset+tailIDsSize]
-		tailIDs, err := sbvector.NewVectorFromBinary(buf)
+		sectionSize, err := reader.readUint32()
 		if err != nil {
-			return ErrorInvalidFormat
+			return err
 		}
-		newtrie.tailIDs = tailIDs
-	} else {
-		if uint32(len(data)) < offset+sizeOfInt32 {
+		buf, err := reader.readBytes(uint64(sectionSize))
+		if err != nil {
+			return err
+		}
+		switch tag {
+		case sectionWeights:
+			if err := trie.unmarshalWeights(buf); err != nil {
+				return err
+			}
+		case sectionLexicographicID:
+			if err := trie.unmarshalLexicographicIDs(buf); err != nil {
+				return err
+			}
+		case sectionTombstones:
+			if err := trie.unmarshalTombstones(buf); err != nil {
+				return err
+			}
+		default:
 			return ErrorInvalidFormat
 		}
-		buf = data[offset : offset+sizeOfInt32]
+	}
+	trie.indexDeletedLexIDs()
+	return nil
+}
 
-		offset += sizeOfInt32
-		vtailSize := binary.LittleEndian.Uint32(buf)
-		newtrie.vtails = make([]string, vtailSize)
-		for i := uint32(0); i < vtailSize; i++ {
-			if uint32(len(data)) < offset+sizeOfInt32 {
-				return ErrorInvalidFormat
+// isASCIITails returns true if all tails consist of ASCII bytes, so that reversing them by runes is same as reversing them by bytes.
+func (trie *TrieData) isASCIITails() bool {
+	numOfTails := trie.tail.NumOfBits(true)
+	for tailID := uint64(0); tailID < numOfTails; tailID++ {
+		str := trie.getTail(tailID)
+		for i := 0; i < len(str); i++ {
+			if str[i] >= utf8.RuneSelf {
+				return false
 			}
-			buf = data[offset : offset+sizeOfInt32]
-			offset += sizeOfInt32
-			strSize := binary.LittleEndian.Uint32(buf)
+		}
+	}
+	return true
+}
 
-			if uint32(len(data)) < offset+strSize {
-				return ErrorInvalidFormat
-			}
-			buf = data[offset : offset+strSize]
-			offset += strSize
-			newtrie.vtails[i] = bytes.NewBuffer(buf).String()
-		}
-	}
-
-	trie.numOfKeys = newtrie.numOfKeys
-	trie.louds = newtrie.louds
-	trie.terminal = newtrie.terminal
-	trie.tail = newtrie.tail
-	trie.edges = make([]byte, edgesSize)
-	copy(trie.edges, newtrie.edges)
-	if newtrie.hasTailTrie {
-		trie.hasTailTrie = true
-		trie.tailTrie = newtrie.tailTrie
-		trie.tailIDSize = newtrie.tailIDSize
-		trie.tailIDs = newtrie.tailIDs
-	} else {
-		trie.hasTailTrie = false
-		trie.vtails = make([]string, len(newtrie.vtails))
-		trie.vtails = newtrie.vtails
+func (trie *TrieData) unmarshalWeights(data []byte) error {
+	reader := &binaryReader{inMemory: true, data: data}
+	weightSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	weights, err := reader.readVector()
+	if err != nil {
+		return err
 	}
+	maxWeights, err := reader.readVector()
+	if err != nil {
+		return err
+	}
+	if ok, _ := reader.atEOF(); !ok {
+		return ErrorInvalidFormat
+	}
+
+	trie.hasWeights = true
+	trie.weightSize = weightSize
+	trie.weights = weights
+	trie.maxWeights = maxWeights
 	return nil
 }
 
@@ -628,3 +1021,28 @@ func max(x uint64, y uint64) uint64 {
 	}
 	return x
 }
+
+func (trie *TrieData) unmarshalLexicographicIDs(data []byte) error {
+	reader := &binaryReader{inMemory: true, data: data}
+	lexIDSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	lexBase, err := reader.readVector()
+	if err != nil {
+		return err
+	}
+	lexRanks, err := reader.readVector()
+	if err != nil {
+		return err
+	}
+	if ok, _ := reader.atEOF(); !ok {
+		return ErrorInvalidFormat
+	}
+
+	trie.hasLexID = true
+	trie.lexIDSize = lexIDSize
+	trie.lexBase = lexBase
+	trie.lexRanks = lexRanks
+	return nil
+}
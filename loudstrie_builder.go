@@ -11,7 +11,11 @@ import (
 trieBuilderData holds information of LOUDS Trie Builder
 */
 type trieBuilderData struct {
-	trie *TrieData
+	trie    *TrieData
+	weights map[string]uint64
+	// sortedWeights holds weight of each key if keyList is already sorted. sortedWeights[i] is weight of keyList[i].
+	sortedWeights   []uint64
+	lexicographicID bool
 }
 
 /*
@@ -20,6 +24,21 @@ trieBuilder is interface of LOUDS Trie Builder
 type trieBuilder interface {
 	Build(keyList []string, useTailTrie bool) (Trie, error)
 	buildTailTrie()
+	buildWeights(keyWeights []uint64)
+	buildLexicographicIDs()
+}
+
+/*
+TrieOptions holds options of building LOUDS Trie.
+*/
+type TrieOptions struct {
+	// UseTailTrie indicates whether to compress TAIL array.
+	UseTailTrie bool
+	// Weights holds weight of each key. Weights[i] is weight of keyList[i].
+	// If keyList has duplicated keys, the largest weight is used.
+	Weights []uint64
+	// LexicographicID indicates whether to assign IDs in lexicographic order of the keys.
+	LexicographicID bool
 }
 
 type rangeNode struct {
@@ -28,7 +47,7 @@ type rangeNode struct {
 }
 
 /*
-NewTrie returns new LOUDS Trie 
+NewTrie returns new LOUDS Trie
 */
 func NewTrie(keyList []string, useTailTrie bool) (Trie, error) {
 	builder := &trieBuilderData{}
@@ -36,6 +55,68 @@ func NewTrie(keyList []string, useTailTrie bool) (Trie, error) {
 	return builder.Build(keyList, useTailTrie)
 }
 
+/*
+NewTrieWithWeights returns new LOUDS Trie that holds weight of each key.
+weights[i] is weight of keyList[i]. If keyList has duplicated keys, the largest weight is used.
+*/
+func NewTrieWithWeights(keyList []string, weights []uint64, useTailTrie bool) (Trie, error) {
+	return NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: useTailTrie, Weights: weights})
+}
+
+/*
+NewTrieWithOptions returns new LOUDS Trie that built with options.
+*/
+func NewTrieWithOptions(keyList []string, options TrieOptions) (Trie, error) {
+	builder := &trieBuilderData{}
+	builder.trie = &TrieData{}
+	builder.lexicographicID = options.LexicographicID
+	if options.Weights != nil {
+		if len(keyList) != len(options.Weights) {
+			return nil, ErrorInvalidWeights
+		}
+		builder.weights = make(map[string]uint64, len(keyList))
+		for i, key := range keyList {
+			if w, ok := builder.weights[key]; !ok || w < options.Weights[i] {
+				builder.weights[key] = options.Weights[i]
+			}
+		}
+		// Build sorts keyList, so copy it to keep correspondence between keyList and weights.
+		sortedKeyList := make([]string, len(keyList))
+		copy(sortedKeyList, keyList)
+		keyList = sortedKeyList
+	}
+	return builder.Build(keyList, options.UseTailTrie)
+}
+
+/*
+newTrieFromSortedKeys returns new LOUDS Trie that built with options from keyList that is sorted and has no duplicated keys.
+keyList isn't sorted again, and options.Weights[i] is used as weight of keyList[i].
+*/
+func newTrieFromSortedKeys(keyList []string, options TrieOptions) (Trie, error) {
+	builder := &trieBuilderData{}
+	builder.trie = &TrieData{}
+	builder.lexicographicID = options.LexicographicID
+	if options.Weights != nil {
+		if len(keyList) != len(options.Weights) {
+			return nil, ErrorInvalidWeights
+		}
+		builder.sortedWeights = options.Weights
+	}
+	return builder.buildSorted(keyList, options.UseTailTrie)
+}
+
+/*
+NewTrieFromByteKeys returns new LOUDS Trie that built with options from []byte keys.
+Keys are copied, so the buffers of keyList can be reused after this function returns.
+*/
+func NewTrieFromByteKeys(keyList [][]byte, options TrieOptions) (Trie, error) {
+	strKeyList := make([]string, len(keyList))
+	for i, key := range keyList {
+		strKeyList[i] = string(key)
+	}
+	return NewTrieWithOptions(strKeyList, options)
+}
+
 func lg2(x uint64) uint64 {
 	ret := uint64(0)
 	for x>>ret != 0 {
@@ -49,9 +130,15 @@ Build builds LOUDS Trie from keyList.
 If useTailTrie is true, compress TAIL array.
 */
 func (builder *trieBuilderData) Build(keyList []string, useTailTrie bool) (Trie, error) {
-	trie := builder.trie
 	sort.Strings(keyList)
 	keyList = removeDuplicates(keyList)
+	return builder.buildSorted(keyList, useTailTrie)
+}
+
+// buildSorted builds LOUDS Trie from keyList that is sorted and has no duplicated keys.
+func (builder *trieBuilderData) buildSorted(keyList []string, useTailTrie bool) (Trie, error) {
+	trie := builder.trie
+	hasWeights := builder.weights != nil || builder.sortedWeights != nil
 	trie.numOfKeys = uint64(len(keyList))
 
 	q := lane.NewQueue()
@@ -68,6 +155,7 @@ func (builder *trieBuilderData) Build(keyList []string, useTailTrie bool) (Trie,
 	treeBuilder.PushBack(false)
 	treeBuilder.PushBack(true)
 
+	var keyWeights []uint64
 	depth := uint64(0)
 	for {
 		if q.Empty() {
@@ -88,6 +176,9 @@ func (builder *trieBuilderData) Build(keyList []string, useTailTrie bool) (Trie,
 			treeBuilder.PushBack(true)
 			terminalBuilder.PushBack(true)
 			tailBuilder.PushBack(true)
+			if hasWeights {
+				keyWeights = append(keyWeights, builder.weightOf(left, cur))
+			}
 			tail := cur[depth:curSize]
 			trie.vtails = append(trie.vtails, tail)
 			continue
@@ -98,6 +189,9 @@ func (builder *trieBuilderData) Build(keyList []string, useTailTrie bool) (Trie,
 		newLeft := left
 		if depth == curSize {
 			terminalBuilder.PushBack(true)
+			if hasWeights {
+				keyWeights = append(keyWeights, builder.weightOf(left, cur))
+			}
 			newLeft++
 			if newLeft == right {
 				treeBuilder.PushBack(true)
@@ -132,10 +226,24 @@ func (builder *trieBuilderData) Build(keyList []string, useTailTrie bool) (Trie,
 	if useTailTrie {
 		builder.buildTailTrie()
 	}
+	if hasWeights {
+		builder.buildWeights(keyWeights)
+	}
+	if builder.lexicographicID {
+		builder.buildLexicographicIDs()
+	}
 	builder.trie = &TrieData{}
 	return trie, nil
 }
 
+// weightOf returns weight of the key that is i-th key of sorted keyList.
+func (builder *trieBuilderData) weightOf(i uint64, key string) uint64 {
+	if builder.sortedWeights != nil {
+		return builder.sortedWeights[i]
+	}
+	return builder.weights[key]
+}
+
 func (builder *trieBuilderData) buildTailTrie() {
 	origTails := builder.trie.vtails
 	keyList := make([]string, len(origTails))
@@ -157,6 +265,82 @@ func (builder *trieBuilderData) buildTailTrie() {
 	builder.trie.vtails = make([]string, 0)
 }
 
+func (builder *trieBuilderData) buildWeights(keyWeights []uint64) {
+	trie := builder.trie
+	numOfNodes := trie.terminal.Size()
+	maxWeights := make([]uint64, numOfNodes)
+	maxWeight := uint64(0)
+	for nodeID := uint64(0); nodeID < numOfNodes; nodeID++ {
+		if ok, _ := trie.terminal.Get(nodeID); ok {
+			rank, _ := trie.terminal.Rank1(nodeID)
+			maxWeights[nodeID] = keyWeights[rank]
+		}
+	}
+	// Children always have larger node ID than their parent.
+	for nodeID := numOfNodes; nodeID > 1; nodeID-- {
+		pos, _ := trie.louds.Select0(nodeID - 1)
+		parent, _ := trie.louds.Rank1(pos)
+		parent--
+		maxWeights[parent] = max(maxWeights[parent], maxWeights[nodeID-1])
+	}
+	if numOfNodes > 0 {
+		maxWeight = maxWeights[0]
+	}
+
+	trie.weightSize = max(lg2(maxWeight), 1)
+	weightsBuilder := sbvector.NewVectorBuilder()
+	for _, w := range keyWeights {
+		weightsBuilder.PushBackBits(w, trie.weightSize)
+	}
+	trie.weights, _ = weightsBuilder.Build(false, false)
+	maxWeightsBuilder := sbvector.NewVectorBuilder()
+	for _, w := range maxWeights {
+		maxWeightsBuilder.PushBackBits(w, trie.weightSize)
+	}
+	trie.maxWeights, _ = maxWeightsBuilder.Build(false, false)
+	trie.hasWeights = true
+}
+
+func (builder *trieBuilderData) buildLexicographicIDs() {
+	trie := builder.trie
+	lexBase := make([]uint64, trie.terminal.Size())
+	lexRanks := make([]uint64, trie.numOfKeys)
+	if trie.numOfKeys != 0 {
+		counter := uint64(0)
+		builder.assignLexicographicIDs(uint64(2), uint64(2), &counter, lexBase, lexRanks)
+	}
+
+	trie.lexIDSize = max(lg2(trie.numOfKeys), 1)
+	lexBaseBuilder := sbvector.NewVectorBuilder()
+	for _, id := range lexBase {
+		lexBaseBuilder.PushBackBits(id, trie.lexIDSize)
+	}
+	trie.lexBase, _ = lexBaseBuilder.Build(false, false)
+	lexRanksBuilder := sbvector.NewVectorBuilder()
+	for _, rank := range lexRanks {
+		lexRanksBuilder.PushBackBits(rank, trie.lexIDSize)
+	}
+	trie.lexRanks, _ = lexRanksBuilder.Build(false, false)
+	trie.hasLexID = true
+}
+
+// assignLexicographicIDs visits nodes in lexicographic order, and records the smallest ID in the subtree of each node.
+func (builder *trieBuilderData) assignLexicographicIDs(pos uint64, zeros uint64, counter *uint64, lexBase []uint64, lexRanks []uint64) {
+	trie := builder.trie
+	ones := pos - zeros
+	lexBase[ones] = *counter
+	if ok, _ := trie.terminal.Get(ones); ok {
+		rank, _ := trie.terminal.Rank1(ones)
+		lexRanks[*counter] = rank
+		*counter++
+	}
+	for i := uint64(0); !trie.isLeaf(pos + i); i++ {
+		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
+		nextPos++
+		builder.assignLexicographicIDs(nextPos, nextPos-zeros-i+uint64(1), counter, lexBase, lexRanks)
+	}
+}
+
 func removeDuplicates(a []string) []string {
 	var result []string
 	seen := make(map[string]bool)
@@ -170,7 +354,22 @@ func removeDuplicates(a []string) []string {
 	return result
 }
 
+// reverseString reverses bytes of str, so that keys that aren't valid UTF-8 are kept as is.
 func reverseString(str string) string {
+	buf := []byte(str)
+	reverseBytes(buf)
+	return string(buf)
+}
+
+// reverseBytes reverses buf in place.
+func reverseBytes(buf []byte) {
+	for i, j := 0, len(buf)-1; i < j; i, j = i+1, j-1 {
+		buf[i], buf[j] = buf[j], buf[i]
+	}
+}
+
+// reverseRunes reverses runes of str. Tails of binary data written by older versions are reversed by runes.
+func reverseRunes(str string) string {
 	runes := []rune(str)
 	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
 		runes[i], runes[j] = runes[j], runes[i]
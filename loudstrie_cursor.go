@@ -0,0 +1,159 @@
+package loudstrie
+
+/*
+Cursor holds a position in LOUDS Trie.
+
+Cursor keeps the position across calls, so it can be used for incremental lookups
+such as per-keystroke search without scanning from the root every time.
+A position inside a TAIL is also regarded as a node of the trie.
+*/
+type Cursor struct {
+	trie    *TrieData
+	pos     uint64
+	zeros   uint64
+	hasTail bool
+	tail    string
+	tailPos uint64
+	depth   uint64
+}
+
+/*
+NewCursor returns new Cursor that points the root of the trie.
+*/
+func (trie *TrieData) NewCursor() *Cursor {
+	cursor := &Cursor{trie: trie}
+	cursor.moveTo(uint64(2), uint64(2))
+	return cursor
+}
+
+func (cursor *Cursor) moveTo(pos uint64, zeros uint64) {
+	cursor.pos = pos
+	cursor.zeros = zeros
+	cursor.tailPos = 0
+	cursor.hasTail, _ = cursor.trie.tail.Get(pos - zeros)
+	if cursor.hasTail {
+		tailID, _ := cursor.trie.tail.Rank1(pos - zeros)
+		cursor.tail = cursor.trie.getTail(tailID)
+	} else {
+		cursor.tail = ""
+	}
+}
+
+/*
+Step moves the cursor to the child labeled c.
+If there is no such child, the cursor isn't moved and this function returns false.
+*/
+func (cursor *Cursor) Step(c byte) bool {
+	if cursor.hasTail {
+		if cursor.tailPos < uint64(len(cursor.tail)) && cursor.tail[cursor.tailPos] == c {
+			cursor.tailPos++
+			cursor.depth++
+			return true
+		}
+		return false
+	}
+	pos := cursor.pos
+	zeros := cursor.zeros
+	cursor.trie.getChild(c, &pos, &zeros)
+	if pos == NotFound {
+		return false
+	}
+	cursor.moveTo(pos, zeros)
+	cursor.depth++
+	return true
+}
+
+/*
+StepString moves the cursor along str.
+If the cursor can't move along whole of str, the cursor isn't moved and this function returns false.
+*/
+func (cursor *Cursor) StepString(str string) bool {
+	saved := *cursor
+	for i := 0; i < len(str); i++ {
+		if !cursor.Step(str[i]) {
+			*cursor = saved
+			return false
+		}
+	}
+	return true
+}
+
+/*
+IsTerminal returns true if the path from the root to the cursor is a key.
+*/
+func (cursor *Cursor) IsTerminal() bool {
+	_, found := cursor.lookupID()
+	return found
+}
+
+/*
+ID returns ID of the key that ends at the cursor.
+If the cursor doesn't point end of a key, this function returns `loudstrie.NotFound`.
+*/
+func (cursor *Cursor) ID() uint64 {
+	id, _ := cursor.lookupID()
+	return id
+}
+
+func (cursor *Cursor) lookupID() (uint64, bool) {
+	if cursor.hasTail && cursor.tailPos != uint64(len(cursor.tail)) {
+		return NotFound, false
+	}
+	return cursor.trie.terminalID(cursor.pos - cursor.zeros)
+}
+
+/*
+Depth returns length of the path from the root to the cursor.
+*/
+func (cursor *Cursor) Depth() uint64 {
+	return cursor.depth
+}
+
+/*
+Children returns labels of the children of the cursor in ascending order.
+*/
+func (cursor *Cursor) Children() []byte {
+	if cursor.hasTail {
+		if cursor.tailPos < uint64(len(cursor.tail)) {
+			return []byte{cursor.tail[cursor.tailPos]}
+		}
+		return nil
+	}
+	var labels []byte
+	for i := uint64(0); !cursor.trie.isLeaf(cursor.pos + i); i++ {
+		labels = append(labels, cursor.trie.edges[cursor.zeros+i-uint64(2)])
+	}
+	return labels
+}
+
+/*
+Parent moves the cursor to the parent.
+If the cursor points the root, the cursor isn't moved and this function returns false.
+*/
+func (cursor *Cursor) Parent() bool {
+	if cursor.hasTail && cursor.tailPos > 0 {
+		cursor.tailPos--
+		cursor.depth--
+		return true
+	}
+	c := byte(0)
+	pos := cursor.pos
+	zeros := cursor.zeros
+	cursor.trie.getParent(&c, &pos, &zeros)
+	if pos == 0 {
+		return false
+	}
+	// getParent returns position of the edge to the node, so find the child list of the parent.
+	pos, zeros = cursor.trie.getNode(pos - zeros)
+	cursor.moveTo(pos, zeros)
+	cursor.depth--
+	return true
+}
+
+/*
+Clone returns copy of the cursor.
+*/
+func (cursor *Cursor) Clone() *Cursor {
+	newCursor := *cursor
+	return &newCursor
+}
@@ -0,0 +1,102 @@
+package loudstrie
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		cursor := (*trie).(*TrieData).NewCursor()
+		if !bytes.Equal(cursor.Children(), []byte("abc")) {
+			t.Error("Expected abc got", string(cursor.Children()))
+		}
+		if cursor.Parent() {
+			t.Error("Root has no parent")
+		}
+		if cursor.Step('d') || cursor.Depth() != 0 {
+			t.Error("Step error for label that does not exist in the trie.")
+		}
+
+		if !cursor.StepString("ab") || cursor.IsTerminal() || cursor.ID() != NotFound {
+			t.Error("Step error", cursor.Depth())
+		}
+		if !bytes.Equal(cursor.Children(), []byte("cl")) {
+			t.Error("Expected cl got", string(cursor.Children()))
+		}
+		clone := cursor.Clone()
+		if !cursor.Step('c') || !cursor.IsTerminal() {
+			t.Error("Step error", cursor.Depth())
+		}
+		key, _ := (*trie).DecodeKey(cursor.ID())
+		if key != "abc" {
+			t.Error("Expected abc got", key)
+		}
+		if clone.Depth() != 2 || clone.IsTerminal() {
+			t.Error("Clone has been changed", clone.Depth())
+		}
+		if cursor.StepString("dx") || cursor.Depth() != 3 {
+			t.Error("StepString error", cursor.Depth())
+		}
+		if !cursor.StepString("de") || !cursor.IsTerminal() || cursor.Children() != nil {
+			t.Error("StepString error", cursor.Depth())
+		}
+		for i := 0; i < 5; i++ {
+			if !cursor.Parent() {
+				t.Error("Parent error", i)
+			}
+		}
+		if cursor.Depth() != 0 || cursor.Parent() {
+			t.Error("Parent error", cursor.Depth())
+		}
+	}
+
+	keyList2 := genKeyList(1000, 20)
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	for _, trie := range tries2 {
+		root := (*trie).(*TrieData).NewCursor()
+		for _, key := range keyList2 {
+			cursor := root.Clone()
+			for i := 0; i < len(key); i++ {
+				if !cursor.Step(key[i]) {
+					t.Error("Step error", key, i)
+					break
+				}
+			}
+			id, _ := (*trie).ExactMatchSearch(key)
+			if cursor.ID() != id {
+				t.Error("Expected", id, "got", cursor.ID())
+			}
+			for cursor.Parent() {
+				if uint64(len(key)) <= cursor.Depth() {
+					t.Error("Parent error", key, cursor.Depth())
+				}
+				if !bytes.Contains(cursor.Children(), []byte{key[cursor.Depth()]}) {
+					t.Error("Children error", key, cursor.Depth())
+				}
+			}
+			if cursor.Depth() != 0 {
+				t.Error("Parent error", key, cursor.Depth())
+			}
+		}
+	}
+
+	trie5, _ := NewTrie([]string{}, false)
+	cursor := trie5.(*TrieData).NewCursor()
+	if cursor.Step('a') || cursor.IsTerminal() || cursor.Children() != nil {
+		t.Error("Cursor error for empty trie.")
+	}
+}
@@ -0,0 +1,88 @@
+package loudstrie
+
+import (
+	"iter"
+)
+
+/*
+DiffKind indicates kind of a difference between two tries.
+*/
+type DiffKind int
+
+const (
+	// DiffAdded indicates that the key is only in the new trie.
+	DiffAdded DiffKind = iota
+	// DiffRemoved indicates that the key is only in the old trie.
+	DiffRemoved
+	// DiffChanged indicates that the key is in both tries, but the value is changed.
+	DiffChanged
+)
+
+/*
+DiffEntry holds a difference between two tries.
+*/
+type DiffEntry struct {
+	// Kind of the difference.
+	Kind DiffKind
+	// Key string.
+	Key string
+	// OldID is ID of the key in the old trie. If the key is added, OldID is `loudstrie.NotFound`.
+	OldID uint64
+	// NewID is ID of the key in the new trie. If the key is removed, NewID is `loudstrie.NotFound`.
+	NewID uint64
+}
+
+/*
+String returns name of the kind.
+*/
+func (kind DiffKind) String() string {
+	switch kind {
+	case DiffAdded:
+		return "added"
+	case DiffRemoved:
+		return "removed"
+	case DiffChanged:
+		return "changed"
+	}
+	return "unknown"
+}
+
+/*
+Diff returns an iterator over keys that are added or removed in newTrie compared to oldTrie.
+
+The differences are computed by walking both tries in lockstep, and yielded in lexicographic order of the keys.
+*/
+func Diff(oldTrie Trie, newTrie Trie) iter.Seq[DiffEntry] {
+	return func(yield func(DiffEntry) bool) {
+		walkSetIDs(oldTrie, newTrie, setOp{onlyA: true, onlyB: true}, func(key string, oldID uint64, newID uint64) bool {
+			return yield(newDiffEntry(key, oldID, newID))
+		})
+	}
+}
+
+/*
+DiffMaps returns an iterator over keys that are added, removed or whose values are changed in newMap compared to oldMap.
+
+equal reports whether two values are same.
+The differences are yielded in lexicographic order of the keys.
+*/
+func DiffMaps[V any](oldMap *Map[V], newMap *Map[V], equal func(V, V) bool) iter.Seq[DiffEntry] {
+	return func(yield func(DiffEntry) bool) {
+		walkSetIDs(oldMap.trie, newMap.trie, setUnion, func(key string, oldID uint64, newID uint64) bool {
+			if oldID != NotFound && newID != NotFound {
+				if equal(oldMap.values[oldID], newMap.values[newID]) {
+					return true
+				}
+				return yield(DiffEntry{DiffChanged, key, oldID, newID})
+			}
+			return yield(newDiffEntry(key, oldID, newID))
+		})
+	}
+}
+
+func newDiffEntry(key string, oldID uint64, newID uint64) DiffEntry {
+	if oldID == NotFound {
+		return DiffEntry{DiffAdded, key, oldID, newID}
+	}
+	return DiffEntry{DiffRemoved, key, oldID, newID}
+}
@@ -0,0 +1,55 @@
+package loudstrie
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	oldKeyList := []string{"able", "abc", "abcde", "bbc", "can"}
+	newKeyList := []string{"ab", "abc", "abcdf", "bbc", "cat"}
+	expected := []DiffEntry{
+		{DiffAdded, "ab", NotFound, 0},
+		{DiffRemoved, "abcde", 0, NotFound},
+		{DiffAdded, "abcdf", NotFound, 0},
+		{DiffRemoved, "able", 0, NotFound},
+		{DiffRemoved, "can", 0, NotFound},
+		{DiffAdded, "cat", NotFound, 0},
+	}
+	for _, useTailTrie := range []bool{true, false} {
+		oldTrie, _ := NewTrie(oldKeyList, useTailTrie)
+		newTrie, _ := NewTrie(newKeyList, !useTailTrie)
+		dynamicTrie, _ := NewDynamicTrie(newTrie)
+		for _, trie := range []Trie{newTrie, dynamicTrie} {
+			var res []DiffEntry
+			for entry := range Diff(oldTrie, trie) {
+				if entry.OldID != NotFound {
+					if key, _ := oldTrie.DecodeKey(entry.OldID); key != entry.Key {
+						t.Error("Expected", entry.Key, "got", key)
+					}
+					entry.OldID = 0
+				}
+				if entry.NewID != NotFound {
+					if key, _ := trie.DecodeKey(entry.NewID); key != entry.Key {
+						t.Error("Expected", entry.Key, "got", key)
+					}
+					entry.NewID = 0
+				}
+				res = append(res, entry)
+			}
+			if !reflect.DeepEqual(res, expected) {
+				t.Error("Expected", expected, "got", res)
+			}
+		}
+	}
+
+	oldMap, _ := NewMap(map[string]string{"a": "1", "b": "2", "c": "3"}, true, StringCodec{})
+	newMap, _ := NewMap(map[string]string{"a": "1", "b": "20", "d": "4"}, false, StringCodec{})
+	var kinds []string
+	for entry := range DiffMaps(oldMap, newMap, func(x string, y string) bool { return x == y }) {
+		kinds = append(kinds, entry.Kind.String()+":"+entry.Key)
+	}
+	if !reflect.DeepEqual(kinds, []string{"changed:b", "removed:c", "added:d"}) {
+		t.Error("DiffMaps error", kinds)
+	}
+}
@@ -0,0 +1,1008 @@
+package loudstrie
+
+import (
+	"bytes"
+	"iter"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+/*
+DynamicTrie is LOUDS Trie that can be updated.
+
+DynamicTrie combines a frozen LOUDS Trie (base) with in-memory inserted keys (delta) and tombstones of deleted keys.
+IDs of keys in the base are same as the base, and IDs of inserted keys follow them in order of insertion.
+IDs are stable until Compact is called. Compact rebuilds the base from all keys, and renumbers IDs.
+
+DynamicTrie is safe for concurrent use. Compact can be called in another goroutine while the trie is searched or updated.
+*/
+type DynamicTrie struct {
+	mutex sync.RWMutex
+	// compactMutex serializes Compact.
+	compactMutex  sync.Mutex
+	base          *TrieData
+	baseNumOfKeys uint64
+	// added holds inserted keys in order of insertion. ID of added[i] is baseNumOfKeys + i.
+	added    []string
+	addedIDs map[string]uint64
+	// sortedAdded holds inserted keys in lexicographic order, except keys in unsortedAdded.
+	sortedAdded []string
+	// unsortedAdded holds keys inserted after sortedAdded is sorted. They are merged into sortedAdded by the next search.
+	unsortedAdded []string
+	// tombstones is bit vector of deleted IDs.
+	tombstones   []uint64
+	numOfDeleted uint64
+	// generation is incremented whenever the base is replaced, so that Traverse detects stale positions.
+	generation uint64
+	// log holds updates while Compact is building the new base.
+	compacting bool
+	log        []dynamicOp
+}
+
+/*
+Position of DynamicTrie.Traverse is held in nodePos and zeros.
+
+nodePos holds node ID of the base whose path is the traversed bytes, dynamicNoBase if no key of the base starts with them,
+or dynamicTailFlag | end<<1 | complete if the traversed bytes end in a TAIL of the base.
+key[:end] is the longest prefix of the query that the key of the TAIL starts with, and complete is 1 if key[:end] is the key.
+zeros holds generation of the base in upper bits, and index of the first inserted key that isn't less than the traversed bytes in lower bits.
+*/
+const (
+	dynamicTailFlag        uint64 = 1 << 63
+	dynamicNoBase                 = dynamicTailFlag - 1
+	dynamicGenerationShift        = 40
+	dynamicAddedMask              = uint64(1)<<dynamicGenerationShift - 1
+)
+
+type dynamicOp struct {
+	key     string
+	deleted bool
+}
+
+/*
+dynamicSnapshot holds inserted keys of DynamicTrie for an iteration, so that the lock isn't held while the caller's loop runs.
+The base isn't modified after reset, so it is shared. Tombstones aren't copied, and they are checked when each key is yielded.
+*/
+type dynamicSnapshot struct {
+	trie          *DynamicTrie
+	base          *TrieData
+	baseNumOfKeys uint64
+	// added holds inserted keys that aren't deleted.
+	added []KeyID
+}
+
+/*
+NewDynamicTrie returns new DynamicTrie that uses the trie as the base.
+The trie must be created by NewTrie, NewTrieWithOptions or NewTrieFromBinary.
+*/
+func NewDynamicTrie(base Trie) (*DynamicTrie, error) {
+	trieData, ok := base.(*TrieData)
+	if !ok {
+		return nil, ErrorUnsupportedTrie
+	}
+	trie := &DynamicTrie{}
+	trie.reset(trieData)
+	return trie, nil
+}
+
+/*
+reset sets the base, and discards the delta and tombstones.
+Keys deleted in the base are moved to tombstones of DynamicTrie, so that they can be inserted again with their IDs.
+*/
+func (trie *DynamicTrie) reset(base *TrieData) {
+	liveBase := *base
+	liveBase.tombstones = nil
+	liveBase.numOfDeleted = 0
+	liveBase.deletedLexIDs = nil
+	trie.base = &liveBase
+	trie.generation++
+	trie.baseNumOfKeys = base.terminal.NumOfBits(true)
+	trie.added = nil
+	trie.addedIDs = make(map[string]uint64)
+	trie.sortedAdded = nil
+	trie.unsortedAdded = nil
+	trie.tombstones = make([]uint64, (trie.baseNumOfKeys+uint64(63))/uint64(64))
+	trie.numOfDeleted = 0
+	for rank := uint64(0); base.numOfDeleted != 0 && rank < trie.baseNumOfKeys; rank++ {
+		if base.isDeleted(rank) {
+			nodeID, _ := base.terminal.Select1(rank)
+			id, _ := liveBase.terminalID(nodeID)
+			trie.setDeleted(id, true)
+		}
+	}
+}
+
+/*
+rlock locks the trie for reading, and returns true if the trie is locked for writing instead.
+Inserted keys are sorted lazily, so if some of them aren't sorted yet, the trie is locked for writing to sort them.
+*/
+func (trie *DynamicTrie) rlock() bool {
+	trie.mutex.RLock()
+	if len(trie.unsortedAdded) == 0 {
+		return false
+	}
+	trie.mutex.RUnlock()
+	trie.mutex.Lock()
+	trie.sortAdded()
+	return true
+}
+
+// runlock unlocks the trie locked by rlock.
+func (trie *DynamicTrie) runlock(exclusive bool) {
+	if exclusive {
+		trie.mutex.Unlock()
+	} else {
+		trie.mutex.RUnlock()
+	}
+}
+
+// sortAdded merges unsortedAdded into sortedAdded. The caller must hold the lock for writing.
+func (trie *DynamicTrie) sortAdded() {
+	if len(trie.unsortedAdded) == 0 {
+		return
+	}
+	unsorted := trie.unsortedAdded
+	slices.Sort(unsorted)
+	sorted := make([]string, 0, len(trie.sortedAdded)+len(unsorted))
+	i := 0
+	for _, key := range trie.sortedAdded {
+		for ; i < len(unsorted) && unsorted[i] < key; i++ {
+			sorted = append(sorted, unsorted[i])
+		}
+		sorted = append(sorted, key)
+	}
+	trie.sortedAdded = append(sorted, unsorted[i:]...)
+	trie.unsortedAdded = nil
+}
+
+func (trie *DynamicTrie) isDeleted(id uint64) bool {
+	return trie.tombstones[id/uint64(64)]&(uint64(1)<<(id%uint64(64))) != 0
+}
+
+// setDeleted updates the tombstone of the ID. If the tombstone is already in the state, this function returns false.
+func (trie *DynamicTrie) setDeleted(id uint64, deleted bool) bool {
+	if trie.isDeleted(id) == deleted {
+		return false
+	}
+	if deleted {
+		trie.tombstones[id/uint64(64)] |= uint64(1) << (id % uint64(64))
+		trie.numOfDeleted++
+	} else {
+		trie.tombstones[id/uint64(64)] &^= uint64(1) << (id % uint64(64))
+		trie.numOfDeleted--
+	}
+	return true
+}
+
+/*
+Insert adds the key to the trie.
+
+This function returns ID of the key. If the key already exists, value of second result parameter is false.
+If the key was deleted, the key is restored with same ID.
+Inserted keys are sorted by the next search that needs them, so Insert doesn't move other keys.
+*/
+func (trie *DynamicTrie) Insert(key string) (uint64, bool) {
+	trie.mutex.Lock()
+	defer trie.mutex.Unlock()
+	if trie.compacting {
+		trie.log = append(trie.log, dynamicOp{key, false})
+	}
+	return trie.insert(key)
+}
+
+func (trie *DynamicTrie) insert(key string) (uint64, bool) {
+	id, found := trie.lookup(key)
+	if found {
+		if !trie.isDeleted(id) {
+			return id, false
+		}
+		trie.setDeleted(id, false)
+		return id, true
+	}
+	id = trie.baseNumOfKeys + uint64(len(trie.added))
+	trie.added = append(trie.added, key)
+	trie.addedIDs[key] = id
+	trie.unsortedAdded = append(trie.unsortedAdded, key)
+	for uint64(len(trie.tombstones)) <= id/uint64(64) {
+		trie.tombstones = append(trie.tombstones, 0)
+	}
+	return id, true
+}
+
+/*
+Delete removes the key from the trie.
+If the key doesn't exist, this function returns false.
+*/
+func (trie *DynamicTrie) Delete(key string) bool {
+	trie.mutex.Lock()
+	defer trie.mutex.Unlock()
+	if trie.compacting {
+		trie.log = append(trie.log, dynamicOp{key, true})
+	}
+	return trie.delete(key)
+}
+
+/*
+DeleteID removes the key of the ID from the trie.
+If the ID doesn't exist, this function returns false.
+*/
+func (trie *DynamicTrie) DeleteID(id uint64) bool {
+	trie.mutex.Lock()
+	defer trie.mutex.Unlock()
+	key, found := trie.decodeKey(id)
+	if !found {
+		return false
+	}
+	if trie.compacting {
+		trie.log = append(trie.log, dynamicOp{key, true})
+	}
+	return trie.delete(key)
+}
+
+func (trie *DynamicTrie) delete(key string) bool {
+	id, found := trie.lookup(key)
+	if !found || trie.isDeleted(id) {
+		return false
+	}
+	trie.setDeleted(id, true)
+	return true
+}
+
+// lookup returns ID of the key, including deleted key.
+func (trie *DynamicTrie) lookup(key string) (uint64, bool) {
+	if id, found := trie.base.ExactMatchSearch(key); found {
+		return id, true
+	}
+	id, found := trie.addedIDs[key]
+	return id, found
+}
+
+/*
+Compact rebuilds the base from all keys, and discards the delta and tombstones.
+
+IDs of keys are renumbered. Searches and updates aren't blocked while the new base is built,
+and updates are applied to the new base after building.
+*/
+func (trie *DynamicTrie) Compact() error {
+	trie.compactMutex.Lock()
+	defer trie.compactMutex.Unlock()
+
+	trie.mutex.Lock()
+	trie.sortAdded()
+	snapshot := trie.snapshot("")
+	trie.compacting = true
+	trie.log = nil
+	trie.mutex.Unlock()
+
+	base := snapshot.base
+	keyList := []string{}
+	var weights []uint64
+	for id, key := range snapshot.all("") {
+		keyList = append(keyList, key)
+		if base.hasWeights {
+			weight := uint64(0)
+			if id < snapshot.baseNumOfKeys {
+				weight = base.weightOf(id)
+			}
+			weights = append(weights, weight)
+		}
+	}
+
+	newBase, err := NewTrieWithOptions(keyList, TrieOptions{
+		UseTailTrie:     base.hasTailTrie,
+		Weights:         weights,
+		LexicographicID: base.hasLexID,
+	})
+
+	trie.mutex.Lock()
+	defer trie.mutex.Unlock()
+	log := trie.log
+	trie.compacting = false
+	trie.log = nil
+	if err != nil {
+		return err
+	}
+	trie.reset(newBase.(*TrieData))
+	for _, op := range log {
+		if op.deleted {
+			trie.delete(op.key)
+		} else {
+			trie.insert(op.key)
+		}
+	}
+	return nil
+}
+
+// overFetch returns limit for the base that covers deleted keys. 0 means no limit, and large limit is saturated to noLimit.
+func (trie *DynamicTrie) overFetch(limit uint64) uint64 {
+	if limit == 0 {
+		return 0
+	}
+	if limit > noLimit-trie.numOfDeleted {
+		return noLimit
+	}
+	return limit + trie.numOfDeleted
+}
+
+// addedWithPrefix returns inserted keys starting with prefix in lexicographic order.
+func (trie *DynamicTrie) addedWithPrefix(prefix string) []KeyID {
+	var res []KeyID
+	for i := sort.SearchStrings(trie.sortedAdded, prefix); i < len(trie.sortedAdded); i++ {
+		key := trie.sortedAdded[i]
+		if !strings.HasPrefix(key, prefix) {
+			break
+		}
+		if id := trie.addedIDs[key]; !trie.isDeleted(id) {
+			res = append(res, KeyID{key, id})
+		}
+	}
+	return res
+}
+
+/*
+commonPrefixes returns keys that are prefixes of text in ascending order of length. The caller must hold the lock.
+Keys of the base are found by traversing the base, and inserted keys are looked up by each length.
+*/
+func (trie *DynamicTrie) commonPrefixes(text string, limit uint64) []Result {
+	var res []Result
+	if limit == 0 {
+		limit = noLimit
+	}
+	nodePos := uint64(0)
+	zeros := uint64(0)
+	keyPos := uint64(0)
+	keyLen := uint64(len(text))
+	// Inserted keys that are shorter than next are already looked up.
+	next := uint64(0)
+	for canTraverse := true; canTraverse && uint64(len(res)) < limit; {
+		var id uint64
+		id, canTraverse = traverse(trie.base, text, keyLen, &nodePos, &zeros, &keyPos)
+		if id == NotFound {
+			continue
+		}
+		// Inserted key can't have same length as a key of the base.
+		length := keyPos - uint64(1)
+		res = trie.appendAddedPrefixes(res, text, next, length, limit)
+		next = length + uint64(1)
+		if !trie.isDeleted(id) && uint64(len(res)) < limit {
+			res = append(res, Result{id, length})
+		}
+	}
+	return trie.appendAddedPrefixes(res, text, next, keyLen+uint64(1), limit)
+}
+
+// appendAddedPrefixes appends inserted keys that are prefixes of text and whose lengths are in [from, to).
+func (trie *DynamicTrie) appendAddedPrefixes(res []Result, text string, from uint64, to uint64, limit uint64) []Result {
+	if len(trie.added) == 0 {
+		return res
+	}
+	for length := from; length < to && uint64(len(res)) < limit; length++ {
+		if id, found := trie.addedIDs[text[:length]]; found && !trie.isDeleted(id) {
+			res = append(res, Result{id, length})
+		}
+	}
+	return res
+}
+
+// mergeKeys merges keys of the base and inserted keys in lexicographic order.
+func (trie *DynamicTrie) mergeKeys(base []KeyID, added []KeyID, limit uint64) []KeyID {
+	var res []KeyID
+	if limit == 0 {
+		limit = noLimit
+	}
+	i := 0
+	j := 0
+	for uint64(len(res)) < limit && (i < len(base) || j < len(added)) {
+		if i < len(base) && trie.isDeleted(base[i].ID) {
+			i++
+			continue
+		}
+		if j == len(added) || (i < len(base) && base[i].Key < added[j].Key) {
+			res = append(res, base[i])
+			i++
+		} else {
+			res = append(res, added[j])
+			j++
+		}
+	}
+	return res
+}
+
+/*
+ExactMatchSearch looks up exact match key with query string.
+
+This function returns id of the exact matched key with query string.
+If couldn't find exact matched key, value of second result parameter is false.
+*/
+func (trie *DynamicTrie) ExactMatchSearch(key string) (uint64, bool) {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	id, found := trie.lookup(key)
+	if !found || trie.isDeleted(id) {
+		return NotFound, false
+	}
+	return id, true
+}
+
+/*
+ExactMatchSearchBytes looks up exact match key with query bytes.
+
+This function is same as ExactMatchSearch, but the query isn't converted to string.
+*/
+func (trie *DynamicTrie) ExactMatchSearchBytes(key []byte) (uint64, bool) {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	id, found := trie.base.ExactMatchSearchBytes(key)
+	if !found {
+		id, found = trie.addedIDs[string(key)]
+	}
+	if !found || trie.isDeleted(id) {
+		return NotFound, false
+	}
+	return id, true
+}
+
+/*
+CommonPrefixSearch looks up keys from the possible prefixes of a query string.
+
+This function returns slice of `Result`. `Result` holds ID and length of the key.
+*/
+func (trie *DynamicTrie) CommonPrefixSearch(key string, limit uint64) []Result {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	return trie.commonPrefixes(key, limit)
+}
+
+/*
+CommonPrefixSearchBytes looks up keys from the possible prefixes of query bytes.
+*/
+func (trie *DynamicTrie) CommonPrefixSearchBytes(key []byte, limit uint64) []Result {
+	return trie.CommonPrefixSearch(string(key), limit)
+}
+
+/*
+PredictiveSearch searches keys starting with a query string.
+This function returns slice of ID in lexicographic order of the keys.
+*/
+func (trie *DynamicTrie) PredictiveSearch(key string, limit uint64) []uint64 {
+	var res []uint64
+	for _, item := range trie.PredictiveSearchKeys(key, limit) {
+		res = append(res, item.ID)
+	}
+	return res
+}
+
+/*
+PredictiveSearchBytes searches keys starting with query bytes.
+*/
+func (trie *DynamicTrie) PredictiveSearchBytes(key []byte, limit uint64) []uint64 {
+	return trie.PredictiveSearch(string(key), limit)
+}
+
+/*
+PredictiveSearchKeys searches keys starting with a query string.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+*/
+func (trie *DynamicTrie) PredictiveSearchKeys(key string, limit uint64) []KeyID {
+	defer trie.runlock(trie.rlock())
+	base := trie.base.PredictiveSearchKeys(key, trie.overFetch(limit))
+	return trie.mergeKeys(base, trie.addedWithPrefix(key), limit)
+}
+
+/*
+TopKPredictive searches k keys that have the largest weights in the keys starting with a query string.
+
+This function returns slice of `WeightedResult` in descending order of weight.
+Weights of inserted keys are 0.
+*/
+func (trie *DynamicTrie) TopKPredictive(key string, k uint64) []WeightedResult {
+	var res []WeightedResult
+	if k == 0 {
+		return res
+	}
+	defer trie.runlock(trie.rlock())
+	for _, item := range trie.base.TopKPredictive(key, trie.overFetch(k)) {
+		if uint64(len(res)) == k {
+			return res
+		}
+		if !trie.isDeleted(item.ID) {
+			res = append(res, item)
+		}
+	}
+	for _, item := range trie.addedWithPrefix(key) {
+		if uint64(len(res)) == k {
+			break
+		}
+		res = append(res, WeightedResult{item.ID, 0})
+	}
+	return res
+}
+
+/*
+All returns an iterator over all keys in lexicographic order.
+The iterator yields ID and key string.
+
+The lock isn't held while the loop runs, so the trie can be searched and updated in the loop.
+Inserted keys are taken when the iteration starts, and keys deleted in the loop aren't yielded.
+If Compact runs in the loop, the iterator continues to yield IDs before Compact.
+*/
+func (trie *DynamicTrie) All() iter.Seq2[uint64, string] {
+	return trie.AllWithPrefix("")
+}
+
+/*
+AllWithPrefix returns an iterator over keys starting with prefix in lexicographic order.
+The iterator yields ID and key string.
+
+The iterator behaves as All while the loop updates the trie.
+*/
+func (trie *DynamicTrie) AllWithPrefix(prefix string) iter.Seq2[uint64, string] {
+	return func(yield func(uint64, string) bool) {
+		exclusive := trie.rlock()
+		snapshot := trie.snapshot(prefix)
+		trie.runlock(exclusive)
+		for id, key := range snapshot.all(prefix) {
+			if !yield(id, key) {
+				return
+			}
+		}
+	}
+}
+
+// snapshot returns the snapshot that holds inserted keys starting with prefix. The caller must hold the lock.
+func (trie *DynamicTrie) snapshot(prefix string) *dynamicSnapshot {
+	return &dynamicSnapshot{
+		trie:          trie,
+		base:          trie.base,
+		baseNumOfKeys: trie.baseNumOfKeys,
+		added:         trie.addedWithPrefix(prefix),
+	}
+}
+
+// isDeleted reports whether the key is deleted now. If the base is replaced by Compact, the key is looked up by its string.
+func (snapshot *dynamicSnapshot) isDeleted(id uint64, key string) bool {
+	trie := snapshot.trie
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	if trie.base != snapshot.base {
+		newID, found := trie.lookup(key)
+		return !found || trie.isDeleted(newID)
+	}
+	return trie.isDeleted(id)
+}
+
+// all enumerates keys starting with prefix. prefix must be same as the prefix of the snapshot.
+func (snapshot *dynamicSnapshot) all(prefix string) iter.Seq2[uint64, string] {
+	return func(yield func(uint64, string) bool) {
+		added := snapshot.added
+		j := 0
+		for id, key := range snapshot.base.AllWithPrefix(prefix) {
+			for ; j < len(added) && added[j].Key < key; j++ {
+				if !snapshot.isDeleted(added[j].ID, added[j].Key) && !yield(added[j].ID, added[j].Key) {
+					return
+				}
+			}
+			if !snapshot.isDeleted(id, key) && !yield(id, key) {
+				return
+			}
+		}
+		for ; j < len(added); j++ {
+			if !snapshot.isDeleted(added[j].ID, added[j].Key) && !yield(added[j].ID, added[j].Key) {
+				return
+			}
+		}
+	}
+}
+
+/*
+PrefixesOf returns an iterator over keys that are prefixes of text in ascending order of length.
+The iterator yields ID and key string.
+
+The keys are found when the iteration starts, and the lock isn't held while the loop runs, so the trie can be searched and updated in the loop.
+*/
+func (trie *DynamicTrie) PrefixesOf(text string) iter.Seq2[uint64, string] {
+	return func(yield func(uint64, string) bool) {
+		trie.mutex.RLock()
+		prefixes := trie.commonPrefixes(text, 0)
+		trie.mutex.RUnlock()
+		for _, item := range prefixes {
+			if !yield(item.ID, text[:item.Length]) {
+				return
+			}
+		}
+	}
+}
+
+/*
+RangeSearch searches keys that are greater than or equal to lo and less than hi.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+*/
+func (trie *DynamicTrie) RangeSearch(lo string, hi string, limit uint64) []KeyID {
+	defer trie.runlock(trie.rlock())
+	var added []KeyID
+	for i := sort.SearchStrings(trie.sortedAdded, lo); i < len(trie.sortedAdded) && trie.sortedAdded[i] < hi; i++ {
+		key := trie.sortedAdded[i]
+		if id := trie.addedIDs[key]; !trie.isDeleted(id) {
+			added = append(added, KeyID{key, id})
+		}
+	}
+	base := trie.base.RangeSearch(lo, hi, trie.overFetch(limit))
+	return trie.mergeKeys(base, added, limit)
+}
+
+/*
+FuzzySearch searches keys whose edit distance to a query string is less than or equal to maxDistance.
+
+This function returns slice of `FuzzyResult` in ascending order of distance, and keys of same distance are in lexicographic order.
+*/
+func (trie *DynamicTrie) FuzzySearch(query string, maxDistance uint64, limit uint64, damerau bool) []FuzzyResult {
+	defer trie.runlock(trie.rlock())
+	var res []FuzzyResult
+	baseLimit := trie.overFetch(limit)
+	if limit == 0 {
+		limit = noLimit
+	}
+	for _, item := range trie.base.FuzzySearch(query, maxDistance, baseLimit, damerau) {
+		if !trie.isDeleted(item.ID) {
+			res = append(res, item)
+		}
+	}
+	searcher := &fuzzySearcher{query: query, maxDistance: maxDistance, damerau: damerau}
+	for _, item := range trie.addedWithPrefix("") {
+		if distance, ok := searcher.distance(item.Key); ok {
+			res = append(res, FuzzyResult{item.Key, item.ID, distance})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Distance != res[j].Distance {
+			return res[i].Distance < res[j].Distance
+		}
+		return res[i].Key < res[j].Key
+	})
+	if uint64(len(res)) > limit {
+		res = res[:limit]
+	}
+	return res
+}
+
+/*
+PatternSearch searches keys matching a glob pattern.
+
+The pattern syntax is same as TrieData.PatternSearch.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+*/
+func (trie *DynamicTrie) PatternSearch(pattern string, limit uint64) ([]KeyID, error) {
+	glob, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	defer trie.runlock(trie.rlock())
+	base, _ := trie.base.PatternSearch(pattern, trie.overFetch(limit))
+	var added []KeyID
+	for _, item := range trie.addedWithPrefix("") {
+		if glob.match(item.Key) {
+			added = append(added, item)
+		}
+	}
+	return trie.mergeKeys(base, added, limit), nil
+}
+
+/*
+RegexpSearch searches keys matching a regular expression.
+
+The syntax of the expression is same as TrieData.RegexpSearch.
+This function returns slice of ID in lexicographic order of the keys.
+*/
+func (trie *DynamicTrie) RegexpSearch(expr string, limit uint64) ([]uint64, error) {
+	matcher, err := compileRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+	defer trie.runlock(trie.rlock())
+	ids, _ := trie.base.RegexpSearch(expr, trie.overFetch(limit))
+	var base []KeyID
+	for _, id := range ids {
+		key, _ := trie.base.DecodeKey(id)
+		base = append(base, KeyID{key, id})
+	}
+	var added []KeyID
+	for _, item := range trie.addedWithPrefix("") {
+		if matcher.match(item.Key) {
+			added = append(added, item)
+		}
+	}
+	var res []uint64
+	for _, item := range trie.mergeKeys(base, added, limit) {
+		res = append(res, item.ID)
+	}
+	return res, nil
+}
+
+/*
+Traverse the trie by one byte of the key.
+
+DynamicTrie doesn't expose its nodes, so nodePos and zeros hold position in the base and in inserted keys, and keyPos advances one byte at a time.
+nodePos is set to `loudstrie.NotFound` when no key starts with the traversed bytes.
+As TrieData.Traverse, deleted keys can make transitions possible.
+This function returns ID of the key that equals to key[:keyPos], and bool value that indicates possible transition to a next byte.
+*/
+func (trie *DynamicTrie) Traverse(key string, keyLen uint64, nodePos *uint64, zeros *uint64, keyPos *uint64) (uint64, bool) {
+	if *nodePos == NotFound {
+		return NotFound, false
+	}
+	defer trie.runlock(trie.rlock())
+	prefix := key[:*keyPos]
+	generation := trie.generation & (noLimit >> dynamicGenerationShift)
+	basePos := *nodePos
+	lo := *zeros & dynamicAddedMask
+	if *keyPos == 0 || *zeros>>dynamicGenerationShift != generation {
+		// The traversal starts, or the base is replaced by Compact.
+		basePos = trie.enterBase(0, 0, key, keyLen)
+		for i := uint64(0); i < *keyPos; i++ {
+			basePos = trie.stepBase(basePos, key, keyLen, i)
+		}
+		lo = uint64(sort.SearchStrings(trie.sortedAdded, prefix))
+	} else {
+		lo = trie.searchAdded(lo, prefix)
+	}
+
+	id := NotFound
+	if basePos&dynamicTailFlag == 0 {
+		if basePos != dynamicNoBase {
+			id, _ = trie.base.terminalID(basePos)
+		}
+	} else if basePos&uint64(1) != 0 && (basePos&^dynamicTailFlag)>>1 == *keyPos {
+		id, _ = trie.base.ExactMatchSearch(prefix)
+	}
+	if id == NotFound && lo < uint64(len(trie.sortedAdded)) && trie.sortedAdded[lo] == prefix {
+		id = trie.addedIDs[prefix]
+	}
+	if id != NotFound && trie.isDeleted(id) {
+		id = NotFound
+	}
+
+	if *keyPos >= keyLen {
+		*keyPos++
+		*nodePos = NotFound
+		return id, false
+	}
+	basePos = trie.stepBase(basePos, key, keyLen, *keyPos)
+	lo = trie.searchAdded(lo, key[:*keyPos+uint64(1)])
+	*keyPos++
+	if basePos == dynamicNoBase && (lo == uint64(len(trie.sortedAdded)) || !strings.HasPrefix(trie.sortedAdded[lo], key[:*keyPos])) {
+		*nodePos = NotFound
+		return id, false
+	}
+	*nodePos = basePos
+	*zeros = generation<<dynamicGenerationShift | lo
+	return id, true
+}
+
+// enterBase returns position of Traverse at the node of the base whose depth is depth.
+func (trie *DynamicTrie) enterBase(nodeID uint64, depth uint64, key string, keyLen uint64) uint64 {
+	base := trie.base
+	if hasTail, _ := base.tail.Get(nodeID); !hasTail {
+		return nodeID
+	}
+	tailID, _ := base.tail.Rank1(nodeID)
+	var n, tailLen uint64
+	if base.hasTailTrie {
+		var buf [64]byte
+		tail := base.appendTail(buf[:0], tailID)
+		n, tailLen = commonPrefixLen(tail, key[depth:keyLen]), uint64(len(tail))
+	} else {
+		tail := base.vtails[tailID]
+		n, tailLen = commonPrefixLen(tail, key[depth:keyLen]), uint64(len(tail))
+	}
+	pos := dynamicTailFlag | (depth+n)<<1
+	if n == tailLen {
+		pos |= uint64(1)
+	}
+	return pos
+}
+
+// stepBase returns position of Traverse after key[keyPos] from the position of the base.
+func (trie *DynamicTrie) stepBase(basePos uint64, key string, keyLen uint64, keyPos uint64) uint64 {
+	switch {
+	case basePos == dynamicNoBase:
+		return dynamicNoBase
+	case basePos&dynamicTailFlag != 0:
+		if keyPos < (basePos&^dynamicTailFlag)>>1 {
+			return basePos
+		}
+		return dynamicNoBase
+	}
+	pos, zeros := trie.base.getNode(basePos)
+	trie.base.getChild(key[keyPos], &pos, &zeros)
+	if pos == NotFound {
+		return dynamicNoBase
+	}
+	return trie.enterBase(pos-zeros, keyPos+uint64(1), key, keyLen)
+}
+
+/*
+searchAdded returns index of the first inserted key that isn't less than prefix.
+The search gallops from hint, so it is fast when the index is near hint. The caller must hold the lock.
+*/
+func (trie *DynamicTrie) searchAdded(hint uint64, prefix string) uint64 {
+	keys := trie.sortedAdded
+	n := uint64(len(keys))
+	if hint > n || (hint > 0 && keys[hint-1] >= prefix) {
+		// Keys are inserted after the hint is taken.
+		return uint64(sort.SearchStrings(keys, prefix))
+	}
+	lo := hint
+	hi := hint
+	for step := uint64(1); hi < n && keys[hi] < prefix; step *= 2 {
+		lo = hi + uint64(1)
+		hi = min(hi+step, n)
+	}
+	return lo + uint64(sort.SearchStrings(keys[lo:hi], prefix))
+}
+
+/*
+DecodeKey returns key string corresponding to the ID.
+*/
+func (trie *DynamicTrie) DecodeKey(id uint64) (string, bool) {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	return trie.decodeKey(id)
+}
+
+/*
+AppendKey appends key corresponding to the ID to dst, and returns the extended buffer.
+If the ID isn't found, dst is returned as is and value of second result parameter is false.
+*/
+func (trie *DynamicTrie) AppendKey(dst []byte, id uint64) ([]byte, bool) {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	if id >= trie.baseNumOfKeys+uint64(len(trie.added)) || trie.isDeleted(id) {
+		return dst, false
+	}
+	if id < trie.baseNumOfKeys {
+		return trie.base.AppendKey(dst, id)
+	}
+	return append(dst, trie.added[id-trie.baseNumOfKeys]...), true
+}
+
+func (trie *DynamicTrie) decodeKey(id uint64) (string, bool) {
+	if id >= trie.baseNumOfKeys+uint64(len(trie.added)) || trie.isDeleted(id) {
+		return "", false
+	}
+	if id < trie.baseNumOfKeys {
+		return trie.base.DecodeKey(id)
+	}
+	return trie.added[id-trie.baseNumOfKeys], true
+}
+
+/*
+GetNumOfKeys returns number of keys in trie.
+*/
+func (trie *DynamicTrie) GetNumOfKeys() uint64 {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	return trie.baseNumOfKeys + uint64(len(trie.added)) - trie.numOfDeleted
+}
+
+/*
+MarshalBinary implements the encoding.BinaryMarshaler interface.
+The base, inserted keys and tombstones are serialized, so IDs are kept.
+Binary data starts with a magic number and a format version, and sizes are 64-bit.
+*/
+func (trie *DynamicTrie) MarshalBinary() ([]byte, error) {
+	trie.mutex.RLock()
+	defer trie.mutex.RUnlock()
+	buffer := new(bytes.Buffer)
+	writer := &binaryWriter{w: buffer}
+	writer.writeContainerHeader(dynamicFormatMagic)
+
+	// base
+	buf, err := trie.base.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	writer.writeUint64(uint64(len(buf)))
+	writer.write(buf)
+
+	// added
+	writer.writeUint64(uint64(len(trie.added)))
+	for _, key := range trie.added {
+		writer.writeUint64(uint64(len(key)))
+		writer.writeString(key)
+	}
+
+	// tombstones
+	writer.writeUint64(uint64(len(trie.tombstones)))
+	for _, word := range trie.tombstones {
+		writer.writeUint64(word)
+	}
+	if writer.err != nil {
+		return nil, writer.err
+	}
+	return buffer.Bytes(), nil
+}
+
+/*
+UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+*/
+func (trie *DynamicTrie) UnmarshalBinary(data []byte) error {
+	reader := &binaryReader{inMemory: true, data: data}
+	if err := reader.readContainerHeader(dynamicFormatMagic); err != nil {
+		return err
+	}
+
+	baseSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	buf, err := reader.readBytes(baseSize)
+	if err != nil {
+		return err
+	}
+	base := new(TrieData)
+	if err := base.UnmarshalBinary(buf); err != nil {
+		return err
+	}
+	newtrie := &DynamicTrie{}
+	newtrie.reset(base)
+
+	addedSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < addedSize; i++ {
+		strSize, err := reader.readUint64()
+		if err != nil {
+			return err
+		}
+		buf, err := reader.readBytes(strSize)
+		if err != nil {
+			return err
+		}
+		// Inserted keys aren't in the base, and deleted keys of the base aren't restored by inserting them.
+		if _, found := newtrie.lookup(string(buf)); found {
+			return ErrorInvalidFormat
+		}
+		newtrie.insert(string(buf))
+	}
+
+	tombstonesSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	// Size is compared by division, so that hostile size doesn't overflow.
+	rest := uint64(len(data)) - uint64(reader.n)
+	if rest%uint64(sizeOfInt64) != 0 || rest/uint64(sizeOfInt64) != tombstonesSize {
+		return ErrorInvalidFormat
+	}
+	numOfAssignedIDs := newtrie.baseNumOfKeys + uint64(len(newtrie.added))
+	for i := uint64(0); i < tombstonesSize; i++ {
+		word, _ := reader.readUint64()
+		for j := uint64(0); j < uint64(64); j++ {
+			if word&(uint64(1)<<j) == 0 {
+				continue
+			}
+			id := i*uint64(64) + j
+			// Tombstones of the base are already set, so overlapping tombstones are invalid.
+			if id >= numOfAssignedIDs || !newtrie.setDeleted(id, true) {
+				return ErrorInvalidFormat
+			}
+		}
+	}
+
+	trie.compactMutex.Lock()
+	defer trie.compactMutex.Unlock()
+	trie.mutex.Lock()
+	defer trie.mutex.Unlock()
+	trie.base = newtrie.base
+	trie.baseNumOfKeys = newtrie.baseNumOfKeys
+	trie.added = newtrie.added
+	trie.addedIDs = newtrie.addedIDs
+	trie.sortedAdded = newtrie.sortedAdded
+	trie.unsortedAdded = newtrie.unsortedAdded
+	trie.tombstones = newtrie.tombstones
+	trie.numOfDeleted = newtrie.numOfDeleted
+	return nil
+}
@@ -0,0 +1,465 @@
+package loudstrie
+
+import (
+	"encoding/binary"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDynamicTrie(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	for _, useTailTrie := range []bool{true, false} {
+		base, _ := NewTrie(keyList, useTailTrie)
+		trie, err := NewDynamicTrie(base)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		ableID, _ := base.ExactMatchSearch("able")
+		if !trie.Delete("able") || trie.Delete("able") || trie.Delete("xyz") {
+			t.Error("Delete error")
+		}
+		if _, found := trie.ExactMatchSearch("able"); found {
+			t.Error("Deleted key is found")
+		}
+		abcdID, inserted := trie.Insert("abcd")
+		if !inserted || abcdID != uint64(len(keyList)) {
+			t.Error("Insert error", abcdID, inserted)
+		}
+		if _, inserted := trie.Insert("abcd"); inserted {
+			t.Error("Insert error for existing key")
+		}
+		trie.Insert("ab")
+		if trie.GetNumOfKeys() != uint64(len(keyList)+1) {
+			t.Error("Expected", len(keyList)+1, "got", trie.GetNumOfKeys())
+		}
+
+		expectedKeys := []string{"ab", "abc", "abcd", "abcde"}
+		res := trie.PredictiveSearchKeys("ab", 0)
+		if len(res) != len(expectedKeys) {
+			t.Error("Expected", expectedKeys, "got", res)
+		}
+		for i, item := range res {
+			if i < len(expectedKeys) && item.Key != expectedKeys[i] {
+				t.Error("Expected", expectedKeys[i], "got", item.Key)
+			}
+			if id, _ := trie.ExactMatchSearch(item.Key); id != item.ID {
+				t.Error("Expected", id, "got", item.ID)
+			}
+			if key, _ := trie.DecodeKey(item.ID); key != item.Key {
+				t.Error("Expected", item.Key, "got", key)
+			}
+			if id, _ := trie.ExactMatchSearchBytes([]byte(item.Key)); id != item.ID {
+				t.Error("Expected", item.ID, "got", id)
+			}
+			if key, _ := trie.AppendKey(nil, item.ID); string(key) != item.Key {
+				t.Error("Expected", item.Key, "got", string(key))
+			}
+		}
+		if _, found := trie.ExactMatchSearchBytes([]byte("able")); found {
+			t.Error("Deleted key is found")
+		}
+		if _, found := trie.AppendKey(nil, ableID); found {
+			t.Error("Deleted key is decoded")
+		}
+		if ids := trie.PredictiveSearch("ab", 2); len(ids) != 2 || ids[0] != res[0].ID || ids[1] != res[1].ID {
+			t.Error("PredictiveSearch error", ids)
+		}
+
+		prefixes := trie.CommonPrefixSearch("abcdef", 0)
+		expectedLens := []uint64{2, 3, 4, 5}
+		if len(prefixes) != len(expectedLens) {
+			t.Error("Expected", expectedLens, "got", prefixes)
+		}
+		for i, item := range prefixes {
+			if i < len(expectedLens) && item.Length != expectedLens[i] {
+				t.Error("Expected", expectedLens[i], "got", item.Length)
+			}
+		}
+		if prefixes := trie.CommonPrefixSearch("abcdef", 3); len(prefixes) != 3 || prefixes[2].Length != 4 {
+			t.Error("CommonPrefixSearch error for limit", prefixes)
+		}
+
+		rangeRes := trie.RangeSearch("abc", "b", 0)
+		if len(rangeRes) != 3 || rangeRes[1].Key != "abcd" {
+			t.Error("RangeSearch error", rangeRes)
+		}
+		fuzzyRes := trie.FuzzySearch("abd", 1, 0, false)
+		if len(fuzzyRes) != 3 || fuzzyRes[0].Key != "ab" || fuzzyRes[2].Key != "abcd" {
+			t.Error("FuzzySearch error", fuzzyRes)
+		}
+		patternRes, _ := trie.PatternSearch("a*d*", 0)
+		if len(patternRes) != 2 || patternRes[0].Key != "abcd" {
+			t.Error("PatternSearch error", patternRes)
+		}
+		regexpRes, _ := trie.RegexpSearch("^ab.?$", 0)
+		if len(regexpRes) != 2 {
+			t.Error("RegexpSearch error", regexpRes)
+		}
+		if topK := trie.TopKPredictive("a", noLimit); len(topK) != 4 {
+			t.Error("TopKPredictive error for large k", topK)
+		}
+		topK := trie.TopKPredictive("a", 10)
+		if len(topK) != 4 {
+			t.Error("TopKPredictive error", topK)
+		}
+
+		if id, inserted := trie.Insert("able"); !inserted || id != ableID {
+			t.Error("Restored key must have same ID", id, ableID)
+		}
+		trie.Delete("abcd")
+
+		bin, err := trie.MarshalBinary()
+		if err != nil {
+			t.Error(err)
+		}
+		newTrie := &DynamicTrie{}
+		if err := newTrie.UnmarshalBinary(bin); err != nil {
+			t.Error(err)
+		}
+		for id, key := range trie.All() {
+			if newID, found := newTrie.ExactMatchSearch(key); !found || newID != id {
+				t.Error("Expected", id, "got", newID)
+			}
+		}
+		if _, found := newTrie.ExactMatchSearch("abcd"); found {
+			t.Error("Deleted key is found")
+		}
+		if err := newTrie.UnmarshalBinary(bin[:len(bin)-1]); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+		if err := newTrie.UnmarshalBinary([]byte{0xfe, 0xff, 0xff, 0xff, 0, 0, 0, 0}); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+		hostile := binary.LittleEndian.AppendUint32([]byte(dynamicFormatMagic), containerFormatVersion)
+		hostile = binary.LittleEndian.AppendUint64(hostile, 0xfffffffffffffffe)
+		if err := newTrie.UnmarshalBinary(hostile); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+
+		if err := trie.Compact(); err != nil {
+			t.Error(err)
+		}
+		expectedKeys = []string{"ab", "abc", "abcde", "able", "bbc", "can"}
+		var keys []string
+		for id, key := range trie.All() {
+			keys = append(keys, key)
+			if id >= uint64(len(expectedKeys)) {
+				t.Error("IDs must be renumbered", id)
+			}
+		}
+		if !reflect.DeepEqual(keys, expectedKeys) {
+			t.Error("Expected", expectedKeys, "got", keys)
+		}
+	}
+}
+
+func TestDynamicTrieRandom(t *testing.T) {
+	keyList := removeDuplicates(genKeyList(1000, 10))
+	base, _ := NewTrie(keyList[:500], true)
+	trie, _ := NewDynamicTrie(base)
+	keySet := make(map[string]bool)
+	for _, key := range keyList[:500] {
+		keySet[key] = true
+	}
+	for i, key := range keyList {
+		if i%3 == 0 {
+			trie.Delete(key)
+			delete(keySet, key)
+		} else {
+			trie.Insert(key)
+			keySet[key] = true
+		}
+	}
+	var expected []string
+	for key := range keySet {
+		expected = append(expected, key)
+	}
+	sort.Strings(expected)
+
+	check := func() {
+		if trie.GetNumOfKeys() != uint64(len(expected)) {
+			t.Error("Expected", len(expected), "got", trie.GetNumOfKeys())
+		}
+		var keys []string
+		for id, key := range trie.All() {
+			keys = append(keys, key)
+			if decoded, _ := trie.DecodeKey(id); decoded != key {
+				t.Error("Expected", key, "got", decoded)
+			}
+		}
+		if !reflect.DeepEqual(keys, expected) {
+			t.Error("All error")
+		}
+		for _, key := range keyList {
+			_, found := trie.ExactMatchSearch(key)
+			if found != keySet[key] {
+				t.Error("ExactMatchSearch error", key)
+			}
+			var prefixes []string
+			for _, item := range trie.CommonPrefixSearch(key, 0) {
+				prefixes = append(prefixes, key[:item.Length])
+			}
+			var expectedPrefixes []string
+			for i := 0; i <= len(key); i++ {
+				if keySet[key[:i]] {
+					expectedPrefixes = append(expectedPrefixes, key[:i])
+				}
+			}
+			if !reflect.DeepEqual(prefixes, expectedPrefixes) {
+				t.Error("Expected", expectedPrefixes, "got", prefixes)
+			}
+		}
+		for _, prefix := range []string{"a", "ab", "b", "ba", "c"} {
+			var keys []string
+			for _, item := range trie.PredictiveSearchKeys(prefix, 10) {
+				keys = append(keys, item.Key)
+			}
+			var expectedKeys []string
+			for _, key := range expected {
+				if strings.HasPrefix(key, prefix) && len(expectedKeys) < 10 {
+					expectedKeys = append(expectedKeys, key)
+				}
+			}
+			if !reflect.DeepEqual(keys, expectedKeys) {
+				t.Error("Expected", expectedKeys, "got", keys)
+			}
+		}
+	}
+	check()
+
+	// Update the trie while compacting.
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := trie.Compact(); err != nil {
+			t.Error(err)
+		}
+	}()
+	for i, key := range keyList {
+		if i%5 == 0 {
+			trie.Insert(key)
+			keySet[key] = true
+		} else if i%7 == 0 {
+			trie.Delete(key)
+			delete(keySet, key)
+		}
+	}
+	wg.Wait()
+	expected = expected[:0]
+	for key := range keySet {
+		expected = append(expected, key)
+	}
+	sort.Strings(expected)
+	check()
+
+	if _, err := NewDynamicTrie(trie); err != ErrorUnsupportedTrie {
+		t.Error("Expected", ErrorUnsupportedTrie, "got", err)
+	}
+}
+
+func TestDynamicTrieDeletedBase(t *testing.T) {
+	for _, lexID := range []bool{false, true} {
+		base, _ := NewTrieWithOptions([]string{"c", "a", "b"}, TrieOptions{LexicographicID: lexID})
+		bID, _ := base.ExactMatchSearch("b")
+		base.(*TrieData).Delete("b")
+		trie, _ := NewDynamicTrie(base)
+		if trie.GetNumOfKeys() != 2 {
+			t.Error("Expected 2 got", trie.GetNumOfKeys())
+		}
+		var keys []string
+		for _, key := range trie.All() {
+			keys = append(keys, key)
+		}
+		if !reflect.DeepEqual(keys, []string{"a", "c"}) {
+			t.Error("Expected [a c] got", keys)
+		}
+		if _, found := trie.ExactMatchSearch("b"); found {
+			t.Error("Deleted key is found")
+		}
+		if id, inserted := trie.Insert("b"); !inserted || id != bID {
+			t.Error("Insert must restore ID", bID, "got", id, inserted)
+		}
+		if trie.GetNumOfKeys() != 3 {
+			t.Error("Expected 3 got", trie.GetNumOfKeys())
+		}
+		if key, _ := trie.DecodeKey(bID); key != "b" {
+			t.Error("Expected b got", key)
+		}
+		if !trie.Delete("a") || trie.GetNumOfKeys() != 2 {
+			t.Error("Delete error", trie.GetNumOfKeys())
+		}
+		// The base given to NewDynamicTrie isn't changed.
+		if _, found := base.ExactMatchSearch("b"); found || base.GetNumOfKeys() != 2 {
+			t.Error("Base must not be changed")
+		}
+	}
+}
+
+// dynamicBinary returns binary data of DynamicTrie that holds the base, inserted keys and tombstones as is.
+func dynamicBinary(base Trie, added []string, tombstones []uint64) []byte {
+	baseBin, _ := base.MarshalBinary()
+	buf := binary.LittleEndian.AppendUint32([]byte(dynamicFormatMagic), containerFormatVersion)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(baseBin)))
+	buf = append(buf, baseBin...)
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(added)))
+	for _, key := range added {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(len(key)))
+		buf = append(buf, key...)
+	}
+	buf = binary.LittleEndian.AppendUint64(buf, uint64(len(tombstones)))
+	for _, word := range tombstones {
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+	}
+	return buf
+}
+
+func TestDynamicTrieInvalidBinary(t *testing.T) {
+	base, _ := NewTrie([]string{"a", "b", "c"}, false)
+	bID, _ := base.ExactMatchSearch("b")
+	base.(*TrieData).Delete("b")
+
+	trie := &DynamicTrie{}
+	if err := trie.UnmarshalBinary(dynamicBinary(base, []string{"d"}, []uint64{1 << 3})); err != nil {
+		t.Error(err)
+	}
+	if trie.GetNumOfKeys() != 2 {
+		t.Error("Expected 2 got", trie.GetNumOfKeys())
+	}
+	// A key is deleted by both tombstones of the base and tombstones of DynamicTrie.
+	if err := trie.UnmarshalBinary(dynamicBinary(base, nil, []uint64{1 << bID})); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+	// A deleted key of the base is inserted again.
+	if err := trie.UnmarshalBinary(dynamicBinary(base, []string{"b"}, []uint64{0})); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+	if err := trie.UnmarshalBinary(dynamicBinary(base, []string{"d", "d"}, []uint64{0})); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+	if trie.GetNumOfKeys() != 2 {
+		t.Error("Invalid binary must not change the trie", trie.GetNumOfKeys())
+	}
+}
+
+func TestDynamicTrieUpdateInLoop(t *testing.T) {
+	base, _ := NewTrie([]string{"a", "ab", "abc"}, false)
+	trie, _ := NewDynamicTrie(base)
+	for id, key := range trie.All() {
+		// The iteration doesn't hold the lock, so updates in the loop don't deadlock.
+		trie.Insert(key + "x")
+		if decoded, _ := trie.DecodeKey(id); decoded != key {
+			t.Error("Expected", key, "got", decoded)
+		}
+	}
+	for _, key := range trie.PrefixesOf("abcd") {
+		trie.Delete(key)
+		trie.GetNumOfKeys()
+	}
+	if err := trie.Compact(); err != nil {
+		t.Error(err)
+	}
+	var keys []string
+	for _, key := range trie.All() {
+		keys = append(keys, key)
+	}
+	if !reflect.DeepEqual(keys, []string{"abcx", "abx", "ax"}) {
+		t.Error("Expected [abcx abx ax] got", keys)
+	}
+
+	// Keys deleted in the loop aren't yielded.
+	keys = keys[:0]
+	for _, key := range trie.All() {
+		keys = append(keys, key)
+		trie.Delete("abx")
+	}
+	if !reflect.DeepEqual(keys, []string{"abcx", "ax"}) {
+		t.Error("Expected [abcx ax] got", keys)
+	}
+	if prefixes := trie.CommonPrefixSearch("abcxy", 1); len(prefixes) != 1 || prefixes[0].Length != 4 {
+		t.Error("CommonPrefixSearch error", prefixes)
+	}
+}
+
+func TestDynamicTrieTraverse(t *testing.T) {
+	var keyList []string
+	for i := 0; i < 200; i++ {
+		key := ""
+		for j := 0; j <= i%7; j++ {
+			key += string("abc"[(i>>j)%3])
+		}
+		keyList = append(keyList, key)
+	}
+	for _, useTailTrie := range []bool{true, false} {
+		base, _ := NewTrie(keyList[:100], useTailTrie)
+		trie, _ := NewDynamicTrie(base)
+		for i, key := range keyList[100:] {
+			trie.Insert(key)
+			if i%3 == 0 {
+				trie.Delete(keyList[i])
+			}
+		}
+		// allKeys holds keys including deleted keys, because deleted keys can make transitions possible.
+		allKeys := removeDuplicates(append([]string{}, keyList...))
+		walk := func(query string, update func(keyPos uint64)) {
+			nodePos := uint64(0)
+			zeros := uint64(0)
+			keyPos := uint64(0)
+			keyLen := uint64(len(query))
+			for {
+				prefix := query[:keyPos]
+				expectedID, found := trie.ExactMatchSearch(prefix)
+				if !found {
+					expectedID = NotFound
+				}
+				expectedNext := false
+				for _, key := range allKeys {
+					if keyPos < keyLen && strings.HasPrefix(key, query[:keyPos+1]) {
+						expectedNext = true
+					}
+				}
+				id, canTraverse := trie.Traverse(query, keyLen, &nodePos, &zeros, &keyPos)
+				if id != expectedID || canTraverse != expectedNext {
+					t.Error("Expected", expectedID, expectedNext, "got", id, canTraverse, query, prefix)
+				}
+				if !canTraverse {
+					return
+				}
+				update(keyPos)
+			}
+		}
+		for _, key := range keyList {
+			walk(key, func(uint64) {})
+			walk(key+"a", func(uint64) {})
+		}
+		// Updates between steps.
+		for i, key := range keyList[:20] {
+			query := key + "cab"
+			walk(query, func(keyPos uint64) {
+				inserted := query[:keyPos] + "c"
+				trie.Insert(inserted)
+				allKeys = append(allKeys, inserted)
+				if keyPos == 2 && i%5 == 0 {
+					if err := trie.Compact(); err != nil {
+						t.Error(err)
+					}
+					// Compact drops deleted keys.
+					allKeys = allKeys[:0]
+					for _, key := range trie.All() {
+						allKeys = append(allKeys, key)
+					}
+				}
+			})
+		}
+	}
+}
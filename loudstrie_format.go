@@ -0,0 +1,435 @@
+package loudstrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"slices"
+
+	"github.com/hideo55/go-sbvector"
+)
+
+/*
+Binary format version 2 consists of a header, a section table and sections.
+
+	header:        magic "LOUDSTRI", format version (uint32), feature flags (uint32), number of sections (uint32), reserved (uint32)
+	section table: tag (uint32), CRC-32C of the section (uint32), offset (uint64) and size (uint64) for each section
+	checksum:      CRC-32C of the header and the section table (uint32)
+	sections:      payloads at the offsets from the head of the binary data
+
+All integers are little endian. Sections whose tags are unknown are skipped, so that sections can be added without bumping the version.
+Data that doesn't start with the magic number is read as the legacy format.
+*/
+const (
+	// formatMagic is magic number at the head of binary data of format version 2.
+	formatMagic = "LOUDSTRI"
+	// formatVersion is version of binary format written by WriteTo and MarshalBinary.
+	formatVersion uint32 = 2
+	// formatHeaderSize is size of the header.
+	formatHeaderSize uint64 = 24
+	// formatEntrySize is size of an entry of the section table.
+	formatEntrySize uint64 = 24
+	// formatMaxSections limits number of sections, so broken header doesn't allocate large memory.
+	formatMaxSections uint32 = 64
+)
+
+// Feature flags of format version 2.
+const (
+	featureTailTrie uint32 = 1 << iota
+	featureWeights
+	featureLexicographicID
+	featureTombstones
+	// featureByteReversedTails indicates that tails in the tail trie are reversed by bytes. If it isn't set, tails are reversed by runes.
+	featureByteReversedTails
+
+	knownFeatures = featureTailTrie | featureWeights | featureLexicographicID | featureTombstones | featureByteReversedTails
+)
+
+// Section tags of format version 2.
+const (
+	formatSectionMeta uint32 = iota + 1
+	formatSectionLouds
+	formatSectionTerminal
+	formatSectionTail
+	formatSectionEdges
+	formatSectionTailTrie
+	formatSectionTailIDs
+	formatSectionVtails
+	formatSectionWeights
+	formatSectionMaxWeights
+	formatSectionLexBase
+	formatSectionLexRanks
+	formatSectionTombstones
+	formatSectionEnd
+)
+
+/*
+Binary formats of Map and DynamicTrie start with their magic number and containerFormatVersion (uint32), and sizes in them are uint64.
+*/
+const (
+	// mapFormatMagic is magic number at the head of binary data of Map.
+	mapFormatMagic = "LOUDSMAP"
+	// dynamicFormatMagic is magic number at the head of binary data of DynamicTrie.
+	dynamicFormatMagic = "LOUDSDYN"
+	// containerFormatVersion is version of binary formats written by MarshalBinary of Map and DynamicTrie.
+	containerFormatVersion uint32 = 2
+)
+
+// sizeOfMeta is size of the meta section.
+const sizeOfMeta = 32
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// formatError is an error of binary format. errors.Is reports that formatError matches ErrorInvalidFormat.
+type formatError struct {
+	message string
+}
+
+// formatSection writes payload of a section.
+type formatSection struct {
+	tag   uint32
+	write func(writer *binaryWriter)
+}
+
+// formatEntry is an entry of the section table.
+type formatEntry struct {
+	tag      uint32
+	checksum uint32
+	offset   uint64
+	size     uint64
+}
+
+func (err *formatError) Error() string {
+	return err.message
+}
+
+func (err *formatError) Is(target error) bool {
+	return target == ErrorInvalidFormat
+}
+
+func (trie *TrieData) formatFeatures() uint32 {
+	features := uint32(0)
+	if trie.hasTailTrie {
+		features |= featureTailTrie
+		if !trie.runeReverse {
+			features |= featureByteReversedTails
+		}
+	}
+	if trie.hasWeights {
+		features |= featureWeights
+	}
+	if trie.hasLexID {
+		features |= featureLexicographicID
+	}
+	if trie.numOfDeleted != 0 {
+		features |= featureTombstones
+	}
+	return features
+}
+
+func (trie *TrieData) formatSections() []formatSection {
+	vector := func(tag uint32, vector sbvector.SuccinctBitVector) formatSection {
+		return formatSection{tag, func(writer *binaryWriter) {
+			writer.writeVectorBinary(vector)
+		}}
+	}
+	sections := []formatSection{
+		{formatSectionMeta, func(writer *binaryWriter) {
+			writer.writeUint64(trie.numOfKeys)
+			writer.writeUint64(trie.tailIDSize)
+			writer.writeUint64(trie.weightSize)
+			writer.writeUint64(trie.lexIDSize)
+		}},
+		vector(formatSectionLouds, trie.louds),
+		vector(formatSectionTerminal, trie.terminal),
+		vector(formatSectionTail, trie.tail),
+		{formatSectionEdges, func(writer *binaryWriter) {
+			writer.write(trie.edges)
+		}},
+	}
+	if trie.hasTailTrie {
+		sections = append(sections,
+			formatSection{formatSectionTailTrie, trie.tailTrie.(*TrieData).writeFormat},
+			vector(formatSectionTailIDs, trie.tailIDs))
+	} else {
+		sections = append(sections, formatSection{formatSectionVtails, func(writer *binaryWriter) {
+			writer.writeUint64(uint64(len(trie.vtails)))
+			for _, str := range trie.vtails {
+				writer.writeUint64(uint64(len(str)))
+				writer.writeString(str)
+			}
+		}})
+	}
+	if trie.hasWeights {
+		sections = append(sections, vector(formatSectionWeights, trie.weights), vector(formatSectionMaxWeights, trie.maxWeights))
+	}
+	if trie.hasLexID {
+		sections = append(sections, vector(formatSectionLexBase, trie.lexBase), vector(formatSectionLexRanks, trie.lexRanks))
+	}
+	if trie.numOfDeleted != 0 {
+		sections = append(sections, formatSection{formatSectionTombstones, func(writer *binaryWriter) {
+			buf, err := trie.marshalTombstones()
+			if err != nil {
+				writer.fail(err)
+				return
+			}
+			writer.write(buf)
+		}})
+	}
+	return sections
+}
+
+/*
+writeFormat writes binary data of format version 2.
+Each section is serialized once into a buffer, and the buffers are written after the section table that holds their sizes and checksums.
+*/
+func (trie *TrieData) writeFormat(writer *binaryWriter) {
+	writeFormatSections(writer, trie.formatFeatures(), trie.formatSections())
+}
+
+func writeFormatSections(writer *binaryWriter, features uint32, sections []formatSection) {
+	entries := make([]formatEntry, len(sections))
+	payloads := make([][]byte, len(sections))
+	offset := formatHeaderSize + uint64(len(sections))*formatEntrySize + uint64(sizeOfInt32)
+	for i, section := range sections {
+		buffer := new(bytes.Buffer)
+		sectionWriter := &binaryWriter{w: buffer}
+		section.write(sectionWriter)
+		if sectionWriter.err != nil {
+			writer.fail(sectionWriter.err)
+			return
+		}
+		payloads[i] = buffer.Bytes()
+		entries[i] = formatEntry{section.tag, crc32.Checksum(payloads[i], castagnoliTable), offset, uint64(len(payloads[i]))}
+		offset += uint64(len(payloads[i]))
+	}
+
+	header := make([]byte, 0, formatHeaderSize+uint64(len(entries))*formatEntrySize+uint64(sizeOfInt32))
+	header = append(header, formatMagic...)
+	header = binary.LittleEndian.AppendUint32(header, formatVersion)
+	header = binary.LittleEndian.AppendUint32(header, features)
+	header = binary.LittleEndian.AppendUint32(header, uint32(len(entries)))
+	header = binary.LittleEndian.AppendUint32(header, 0)
+	for _, entry := range entries {
+		header = binary.LittleEndian.AppendUint32(header, entry.tag)
+		header = binary.LittleEndian.AppendUint32(header, entry.checksum)
+		header = binary.LittleEndian.AppendUint64(header, entry.offset)
+		header = binary.LittleEndian.AppendUint64(header, entry.size)
+	}
+	header = binary.LittleEndian.AppendUint32(header, crc32.Checksum(header, castagnoliTable))
+	writer.write(header)
+
+	for _, payload := range payloads {
+		writer.write(payload)
+	}
+}
+
+/*
+readFrom reads binary data of format version 2 or the legacy format.
+Legacy binary data has no magic number, so broken legacy binary data is reported as ErrorNotLoudsTrie.
+*/
+func (trie *TrieData) readFrom(reader *binaryReader) error {
+	if reader.hasPrefix(formatMagic) {
+		return trie.readFormat(reader)
+	}
+	if err := trie.readLegacy(reader); err != nil {
+		if err == ErrorInvalidFormat {
+			return ErrorNotLoudsTrie
+		}
+		return err
+	}
+	return nil
+}
+
+// readFormat reads binary data of format version 2.
+func (trie *TrieData) readFormat(reader *binaryReader) error {
+	start := reader.n
+	header, err := reader.readBytes(formatHeaderSize)
+	if err != nil {
+		return err
+	}
+	if version := binary.LittleEndian.Uint32(header[8:]); version != formatVersion {
+		return ErrorUnsupportedVersion
+	}
+	features := binary.LittleEndian.Uint32(header[12:])
+	if features&^knownFeatures != 0 {
+		return ErrorUnsupportedVersion
+	}
+	numOfSections := binary.LittleEndian.Uint32(header[16:])
+	if numOfSections > formatMaxSections {
+		return ErrorInvalidFormat
+	}
+	table, err := reader.readBytes(uint64(numOfSections)*formatEntrySize + uint64(sizeOfInt32))
+	if err != nil {
+		return err
+	}
+	checksum := crc32.Update(crc32.Checksum(header, castagnoliTable), castagnoliTable, table[:len(table)-int(sizeOfInt32)])
+	if checksum != binary.LittleEndian.Uint32(table[len(table)-int(sizeOfInt32):]) {
+		return ErrorChecksumMismatch
+	}
+	entries := make([]formatEntry, numOfSections)
+	for i := range entries {
+		buf := table[uint64(i)*formatEntrySize:]
+		entries[i] = formatEntry{
+			tag:      binary.LittleEndian.Uint32(buf),
+			checksum: binary.LittleEndian.Uint32(buf[4:]),
+			offset:   binary.LittleEndian.Uint64(buf[8:]),
+			size:     binary.LittleEndian.Uint64(buf[16:]),
+		}
+	}
+	slices.SortStableFunc(entries, func(x formatEntry, y formatEntry) int {
+		switch {
+		case x.offset < y.offset:
+			return -1
+		case x.offset > y.offset:
+			return 1
+		}
+		return 0
+	})
+
+	trie.hasTailTrie = features&featureTailTrie != 0
+	trie.runeReverse = trie.hasTailTrie && features&featureByteReversedTails == 0
+	trie.hasWeights = features&featureWeights != 0
+	trie.hasLexID = features&featureLexicographicID != 0
+	expected := []uint32{formatSectionMeta, formatSectionLouds, formatSectionTerminal, formatSectionTail, formatSectionEdges}
+	if trie.hasTailTrie {
+		expected = append(expected, formatSectionTailTrie, formatSectionTailIDs)
+	} else {
+		expected = append(expected, formatSectionVtails)
+	}
+	if trie.hasWeights {
+		expected = append(expected, formatSectionWeights, formatSectionMaxWeights)
+	}
+	if trie.hasLexID {
+		expected = append(expected, formatSectionLexBase, formatSectionLexRanks)
+	}
+	if features&featureTombstones != 0 {
+		expected = append(expected, formatSectionTombstones)
+	}
+
+	var found [formatSectionEnd]bool
+	var tombstones []byte
+	for _, entry := range entries {
+		pos := uint64(reader.n - start)
+		if entry.offset < pos {
+			return ErrorInvalidFormat
+		}
+		if _, err := reader.readBytes(entry.offset - pos); err != nil {
+			return err
+		}
+		payload, err := reader.readBytes(entry.size)
+		if err != nil {
+			return err
+		}
+		if !reader.skipChecksum && crc32.Checksum(payload, castagnoliTable) != entry.checksum {
+			return ErrorChecksumMismatch
+		}
+		if entry.tag == 0 || entry.tag >= formatSectionEnd {
+			// Unknown section
+			continue
+		}
+		if found[entry.tag] || !slices.Contains(expected, entry.tag) {
+			return ErrorInvalidFormat
+		}
+		found[entry.tag] = true
+		if entry.tag == formatSectionTombstones {
+			// Tombstones are read after the terminal section.
+			tombstones = payload
+			continue
+		}
+		// Payload of a section read from io.Reader isn't shared, so it can be referred by the trie.
+		section := &binaryReader{inMemory: true, data: payload, noCopy: reader.noCopy || !reader.inMemory, skipChecksum: reader.skipChecksum}
+		if err := trie.readFormatSection(entry.tag, section); err != nil {
+			return err
+		}
+	}
+	for _, tag := range expected {
+		if !found[tag] {
+			return ErrorInvalidFormat
+		}
+	}
+	if tombstones != nil {
+		if err := trie.unmarshalTombstones(tombstones); err != nil {
+			return err
+		}
+		trie.indexDeletedLexIDs()
+	}
+	if ok, err := reader.atEOF(); err != nil || !ok {
+		return ErrorInvalidFormat
+	}
+	return nil
+}
+
+func (trie *TrieData) readFormatSection(tag uint32, section *binaryReader) error {
+	var err error
+	vector := func() sbvector.SuccinctBitVector {
+		var v sbvector.SuccinctBitVector
+		if v, err = sbvector.NewVectorFromBinary(section.data); err != nil {
+			err = ErrorInvalidFormat
+		}
+		return v
+	}
+	switch tag {
+	case formatSectionMeta:
+		if len(section.data) != sizeOfMeta {
+			return ErrorInvalidFormat
+		}
+		trie.numOfKeys = binary.LittleEndian.Uint64(section.data)
+		trie.tailIDSize = binary.LittleEndian.Uint64(section.data[8:])
+		trie.weightSize = binary.LittleEndian.Uint64(section.data[16:])
+		trie.lexIDSize = binary.LittleEndian.Uint64(section.data[24:])
+		return nil
+	case formatSectionLouds:
+		trie.louds = vector()
+	case formatSectionTerminal:
+		trie.terminal = vector()
+	case formatSectionTail:
+		trie.tail = vector()
+	case formatSectionEdges:
+		trie.edges = section.ownBytes(section.data)
+	case formatSectionTailTrie:
+		tailTrie := new(TrieData)
+		if err := tailTrie.readFrom(section); err != nil {
+			return err
+		}
+		if ok, _ := section.atEOF(); !ok {
+			return ErrorInvalidFormat
+		}
+		trie.tailTrie = tailTrie
+	case formatSectionTailIDs:
+		trie.tailIDs = vector()
+	case formatSectionVtails:
+		numOfVtails, err := section.readUint64()
+		if err != nil {
+			return err
+		}
+		// Each string has its size, so broken number of strings is detected before allocation.
+		if numOfVtails > uint64(len(section.data))/uint64(sizeOfInt64) {
+			return ErrorInvalidFormat
+		}
+		trie.vtails = make([]string, 0, numOfVtails)
+		for i := uint64(0); i < numOfVtails; i++ {
+			strSize, err := section.readUint64()
+			if err != nil {
+				return err
+			}
+			buf, err := section.readBytes(strSize)
+			if err != nil {
+				return err
+			}
+			trie.vtails = append(trie.vtails, section.ownString(buf))
+		}
+		if ok, _ := section.atEOF(); !ok {
+			return ErrorInvalidFormat
+		}
+	case formatSectionWeights:
+		trie.weights = vector()
+	case formatSectionMaxWeights:
+		trie.maxWeights = vector()
+	case formatSectionLexBase:
+		trie.lexBase = vector()
+	case formatSectionLexRanks:
+		trie.lexRanks = vector()
+	}
+	return err
+}
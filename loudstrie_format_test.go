@@ -0,0 +1,149 @@
+package loudstrie
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"testing"
+)
+
+func TestFormatVersion2(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	weights := make([]uint64, len(keyList))
+	for i := range weights {
+		weights[i] = uint64(i)
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	trie3, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, Weights: weights, LexicographicID: true})
+	trie3.(*TrieData).Delete(keyList[0])
+	tries := []*Trie{&trie1, &trie2, &trie3}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		bin, _ := trieData.MarshalBinary()
+		if !bytes.HasPrefix(bin, []byte(formatMagic)) {
+			t.Error("MarshalBinary must write magic number")
+		}
+		legacy, err := trieData.MarshalBinaryLegacy()
+		if err != nil {
+			t.Error(err)
+		}
+		if bytes.HasPrefix(legacy, []byte(formatMagic)) {
+			t.Error("MarshalBinaryLegacy must not write magic number")
+		}
+
+		for _, data := range [][]byte{bin, legacy} {
+			newTrie, err := NewTrieFromBinary(data)
+			if err != nil {
+				t.Error(err)
+				continue
+			}
+			streamTrie := new(TrieData)
+			if _, err := streamTrie.ReadFrom(bytes.NewReader(data)); err != nil {
+				t.Error(err)
+				continue
+			}
+			for _, loaded := range []Trie{newTrie, streamTrie} {
+				if loaded.GetNumOfKeys() != trieData.GetNumOfKeys() {
+					t.Error("Expected", trieData.GetNumOfKeys(), "got", loaded.GetNumOfKeys())
+				}
+				for _, key := range keyList {
+					id1, found1 := trieData.ExactMatchSearch(key)
+					id2, found2 := loaded.ExactMatchSearch(key)
+					if id1 != id2 || found1 != found2 {
+						t.Error("Expected", id1, "got", id2)
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestFormatErrors(t *testing.T) {
+	keyList := genKeyList(100, 10)
+	trie, _ := NewTrie(keyList, true)
+	bin, _ := trie.MarshalBinary()
+
+	modify := func(f func(data []byte)) []byte {
+		data := bytes.Clone(bin)
+		f(data)
+		return data
+	}
+	tests := []struct {
+		name     string
+		data     []byte
+		expected error
+	}{
+		{"not loudstrie", []byte("this is not a trie"), ErrorNotLoudsTrie},
+		{"version", modify(func(data []byte) { binary.LittleEndian.PutUint32(data[8:], formatVersion+1) }), ErrorUnsupportedVersion},
+		{"features", modify(func(data []byte) { data[15] |= 0x80 }), ErrorUnsupportedVersion},
+		{"section table", modify(func(data []byte) { data[formatHeaderSize+8]++ }), ErrorChecksumMismatch},
+		{"section", modify(func(data []byte) { data[len(data)-1]++ }), ErrorChecksumMismatch},
+		{"trailing data", append(bytes.Clone(bin), 0), ErrorInvalidFormat},
+	}
+	for _, test := range tests {
+		_, err := NewTrieFromBinary(test.data)
+		if err != test.expected {
+			t.Error(test.name, "Expected", test.expected, "got", err)
+		}
+		if !errors.Is(err, ErrorInvalidFormat) {
+			t.Error(test.name, "must be ErrorInvalidFormat")
+		}
+		if _, err := new(TrieData).ReadFrom(bytes.NewReader(test.data)); err != test.expected {
+			t.Error(test.name, "Expected", test.expected, "got", err)
+		}
+	}
+}
+
+func TestFormatLargeSection(t *testing.T) {
+	keyList := genKeyList(100, 10)
+	trie, _ := NewTrie(keyList, false)
+	bin, _ := trie.MarshalBinary()
+
+	// Offset and size of the last section are set to 64-bit values that exceed the data.
+	tableEnd := formatHeaderSize + uint64(binary.LittleEndian.Uint32(bin[16:]))*formatEntrySize
+	for _, value := range []uint64{uint64(1) << 32, ^uint64(0)} {
+		data := bytes.Clone(bin)
+		binary.LittleEndian.PutUint64(data[tableEnd-16:], value)
+		binary.LittleEndian.PutUint64(data[tableEnd-8:], value)
+		binary.LittleEndian.PutUint32(data[tableEnd:], crc32.Checksum(data[:tableEnd], castagnoliTable))
+		if _, err := NewTrieFromBinary(data); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+		if _, err := new(TrieData).ReadFrom(bytes.NewReader(data)); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+	}
+}
+
+func TestFormatUnknownSection(t *testing.T) {
+	keyList := genKeyList(100, 10)
+	trie, _ := NewTrie(keyList, false)
+	trieData := trie.(*TrieData)
+	sections := append(trieData.formatSections(), formatSection{formatSectionEnd + 100, func(writer *binaryWriter) {
+		writer.writeString("unknown")
+	}})
+	var buffer bytes.Buffer
+	writeFormatSections(&binaryWriter{w: &buffer}, trieData.formatFeatures(), sections)
+
+	newTrie, err := NewTrieFromBinary(buffer.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range keyList {
+		id1, _ := trie.ExactMatchSearch(key)
+		id2, _ := newTrie.ExactMatchSearch(key)
+		if id1 != id2 {
+			t.Error("Expected", id1, "got", id2)
+		}
+	}
+
+	// Lack of a required section is an error.
+	buffer.Reset()
+	writeFormatSections(&binaryWriter{w: &buffer}, trieData.formatFeatures(), trieData.formatSections()[1:])
+	if _, err := NewTrieFromBinary(buffer.Bytes()); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+}
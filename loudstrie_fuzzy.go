@@ -0,0 +1,167 @@
+package loudstrie
+
+import (
+	"slices"
+	"sort"
+)
+
+/*
+FuzzyResult holds result of fuzzy search.
+*/
+type FuzzyResult struct {
+	// Key string.
+	Key string
+	// ID of the key.
+	ID uint64
+	// Edit distance between the key and the query string.
+	Distance uint64
+}
+
+type fuzzySearcher struct {
+	trie        *TrieData
+	query       string
+	maxDistance uint64
+	damerau     bool
+	limit       uint64
+	// done is true if limit results of distance 0 are found.
+	done bool
+	// rows[d] is row of the edit distance table for the path of depth d.
+	rows [][]uint64
+	path []byte
+	res  []FuzzyResult
+}
+
+/*
+FuzzySearch searches keys whose edit distance to a query string is less than or equal to maxDistance.
+
+Edit distance is Levenshtein distance. If damerau is true, transposition of two adjacent bytes is also counted as one edit.
+Distance is computed in bytes.
+This function returns slice of `FuzzyResult` in ascending order of distance, and keys of same distance are in lexicographic order.
+If limit is given, the search is pruned by distance of the limit-th result found so far, so a small limit reduces the search.
+*/
+func (trie *TrieData) FuzzySearch(query string, maxDistance uint64, limit uint64, damerau bool) []FuzzyResult {
+	if limit == 0 {
+		limit = noLimit
+	}
+	searcher := &fuzzySearcher{
+		trie:        trie,
+		query:       query,
+		maxDistance: maxDistance,
+		damerau:     damerau,
+		limit:       limit,
+	}
+	row := searcher.getRow(0)
+	for j := range row {
+		row[j] = uint64(j)
+	}
+	searcher.search(uint64(2), uint64(2), 0)
+
+	res := searcher.res
+	if limit == noLimit {
+		sort.SliceStable(res, func(i, j int) bool {
+			return res[i].Distance < res[j].Distance
+		})
+	}
+	return res
+}
+
+/*
+add adds the result found by the search.
+If limit is given, results are kept sorted, and maxDistance is lowered once limit results are found,
+so that only keys closer than the limit-th result are searched.
+*/
+func (searcher *fuzzySearcher) add(result FuzzyResult) {
+	if searcher.limit == noLimit {
+		searcher.res = append(searcher.res, result)
+		return
+	}
+	// Keys are found in lexicographic order, so the result is placed after results of same distance.
+	res := searcher.res
+	i := sort.Search(len(res), func(i int) bool { return res[i].Distance > result.Distance })
+	res = slices.Insert(res, i, result)
+	if uint64(len(res)) >= searcher.limit {
+		res = res[:searcher.limit]
+		if last := res[len(res)-1].Distance; last == 0 {
+			searcher.done = true
+		} else {
+			searcher.maxDistance = last - uint64(1)
+		}
+	}
+	searcher.res = res
+}
+
+func (searcher *fuzzySearcher) getRow(depth int) []uint64 {
+	for len(searcher.rows) <= depth {
+		searcher.rows = append(searcher.rows, make([]uint64, len(searcher.query)+1))
+	}
+	return searcher.rows[depth]
+}
+
+// step computes the row for the path extended by c, and returns false if no key in the subtree can be within maxDistance.
+func (searcher *fuzzySearcher) step(depth int, c byte) bool {
+	searcher.path = append(searcher.path[:depth], c)
+	prev := searcher.getRow(depth)
+	row := searcher.getRow(depth + 1)
+	query := searcher.query
+	row[0] = prev[0] + uint64(1)
+	minDistance := row[0]
+	for j := 1; j <= len(query); j++ {
+		cost := uint64(1)
+		if query[j-1] == c {
+			cost = uint64(0)
+		}
+		row[j] = min(prev[j]+uint64(1), row[j-1]+uint64(1), prev[j-1]+cost)
+		if searcher.damerau && depth > 0 && j > 1 && query[j-2] == c && query[j-1] == searcher.path[depth-1] {
+			row[j] = min(row[j], searcher.rows[depth-1][j-2]+uint64(1))
+		}
+		minDistance = min(minDistance, row[j])
+	}
+	return minDistance <= searcher.maxDistance
+}
+
+func (searcher *fuzzySearcher) search(pos uint64, zeros uint64, depth int) {
+	if searcher.done {
+		return
+	}
+	trie := searcher.trie
+	ones := pos - zeros
+	if ok, _ := trie.tail.Get(ones); ok {
+		tailID, _ := trie.tail.Rank1(ones)
+		tail := trie.getTail(tailID)
+		for i := 0; i < len(tail); i++ {
+			if !searcher.step(depth, tail[i]) {
+				return
+			}
+			depth++
+		}
+	}
+	if id, found := trie.terminalID(ones); found {
+		distance := searcher.rows[depth][len(searcher.query)]
+		if distance <= searcher.maxDistance {
+			searcher.add(FuzzyResult{string(searcher.path[:depth]), id, distance})
+		}
+	}
+	for i := uint64(0); !searcher.done && !trie.isLeaf(pos+i); i++ {
+		if !searcher.step(depth, trie.edges[zeros+i-uint64(2)]) {
+			continue
+		}
+		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
+		nextPos++
+		searcher.search(nextPos, nextPos-zeros-i+uint64(1), depth+1)
+	}
+}
+
+// distance returns edit distance between the key and the query string, and returns false if it exceeds maxDistance.
+func (searcher *fuzzySearcher) distance(key string) (uint64, bool) {
+	row := searcher.getRow(0)
+	for j := range row {
+		row[j] = uint64(j)
+	}
+	for i := 0; i < len(key); i++ {
+		if !searcher.step(i, key[i]) {
+			return 0, false
+		}
+	}
+	distance := searcher.rows[len(key)][len(searcher.query)]
+	return distance, distance <= searcher.maxDistance
+}
@@ -0,0 +1,113 @@
+package loudstrie
+
+import (
+	"slices"
+	"testing"
+)
+
+func editDistance(a string, b string, damerau bool) uint64 {
+	d := make([][]uint64, len(a)+1)
+	for i := range d {
+		d[i] = make([]uint64, len(b)+1)
+		d[i][0] = uint64(i)
+	}
+	for j := range d[0] {
+		d[0][j] = uint64(j)
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := uint64(1)
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if damerau && i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func TestFuzzySearch(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		results := trieData.FuzzySearch("abd", 1, 0, false)
+		if len(results) != 1 || results[0].Key != "abc" || results[0].Distance != 1 {
+			t.Error(results)
+		}
+		results = trieData.FuzzySearch("abc", 2, 0, false)
+		if len(results) != 4 || results[0].Key != "abc" || results[0].Distance != 0 {
+			t.Error(results)
+		}
+		for _, item := range results {
+			if id, _ := (*trie).ExactMatchSearch(item.Key); id != item.ID {
+				t.Error("Expected", id, "got", item.ID)
+			}
+		}
+		results = trieData.FuzzySearch("abc", 2, 2, false)
+		if len(results) != 2 {
+			t.Error(results)
+		}
+		results = trieData.FuzzySearch("albe", 1, 0, false)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+		results = trieData.FuzzySearch("albe", 1, 0, true)
+		if len(results) != 1 || results[0].Key != "able" {
+			t.Error(results)
+		}
+	}
+
+	keyList2 := genKeyList(2000, 8)
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	keyList2 = removeDuplicates(keyList2)
+	for _, trie := range tries2 {
+		trieData := (*trie).(*TrieData)
+		for _, query := range keyList2[:20] {
+			for _, damerau := range []bool{false, true} {
+				expected := make(map[string]uint64)
+				for _, key := range keyList2 {
+					if d := editDistance(key, query, damerau); d <= 2 {
+						expected[key] = d
+					}
+				}
+				results := trieData.FuzzySearch(query, 2, 0, damerau)
+				if len(results) != len(expected) {
+					t.Error("Expected", len(expected), "got", len(results), query)
+				}
+				for i, item := range results {
+					if d, ok := expected[item.Key]; !ok || d != item.Distance {
+						t.Error("Expected", d, "got", item.Distance, item.Key, query)
+					}
+					if i > 0 && results[i-1].Distance > item.Distance {
+						t.Error("Results are not sorted by distance.", query)
+					}
+				}
+				for _, limit := range []uint64{1, 3, 10} {
+					limited := trieData.FuzzySearch(query, 2, limit, damerau)
+					expectedResults := results
+					if uint64(len(expectedResults)) > limit {
+						expectedResults = expectedResults[:limit]
+					}
+					if !slices.Equal(limited, expectedResults) {
+						t.Error("Expected", expectedResults, "got", limited, query, limit)
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package loudstrie
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io"
+	"math"
+	"os"
+	"slices"
+	"unsafe"
+
+	"github.com/hideo55/go-sbvector"
+)
+
+// readChunkSize is maximum size of memory that is allocated before reading data.
+const readChunkSize uint64 = 1 << 20
+
+// binaryWriter writes binary data and counts written bytes. The first error is held and later writes are skipped.
+type binaryWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+// binaryReader reads binary data and counts read bytes.
+type binaryReader struct {
+	r io.Reader
+	n int64
+	// buffered is set if r is buffered, so that head of data can be peeked.
+	buffered *bufio.Reader
+	// inMemory indicates that binary data is held in data. Slices returned by readBytes refer to data.
+	inMemory bool
+	data     []byte
+	// noCopy indicates that the trie refers to data instead of copying it.
+	noCopy bool
+	// skipChecksum indicates that checksums of sections aren't verified.
+	skipChecksum bool
+}
+
+/*
+LoadOptions holds options to load a trie from binary data.
+*/
+type LoadOptions struct {
+	// SkipValidation skips Validate after loading and verification of checksums of sections.
+	// Use it only for trusted data, because searches on broken data may panic.
+	SkipValidation bool
+	// NoCopy makes the trie refer to the binary data instead of copying edges and TAIL strings.
+	// The binary data must not be modified while the trie is used.
+	NoCopy bool
+}
+
+/*
+NewTrieFromBinaryWithOptions returns new Trie that initialize by binary data with options.
+*/
+func NewTrieFromBinaryWithOptions(binData []byte, options LoadOptions) (Trie, error) {
+	trie := new(TrieData)
+	if err := trie.load(&binaryReader{inMemory: true, data: binData, noCopy: options.NoCopy}, options); err != nil {
+		return new(TrieData), err
+	}
+	return trie, nil
+}
+
+/*
+Save writes the trie to the file.
+*/
+func (trie *TrieData) Save(path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(file)
+	if _, err := trie.WriteTo(writer); err != nil {
+		file.Close()
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+/*
+Load returns new Trie that initialize by the file written by Save.
+*/
+func Load(path string) (Trie, error) {
+	return LoadWithOptions(path, LoadOptions{})
+}
+
+/*
+LoadWithOptions returns new Trie that initialize by the file written by Save with options.
+The file is read as a stream, so options.NoCopy is ignored.
+*/
+func LoadWithOptions(path string, options LoadOptions) (Trie, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	trie := new(TrieData)
+	err = trie.load(newStreamReader(file), options)
+	return trie, err
+}
+
+// load reads the trie, and validates it unless options.SkipValidation is true. If an error occurs, the trie isn't changed.
+func (trie *TrieData) load(reader *binaryReader, options LoadOptions) error {
+	reader.skipChecksum = options.SkipValidation
+	newtrie := new(TrieData)
+	if err := newtrie.readFrom(reader); err != nil {
+		return err
+	}
+	if !options.SkipValidation {
+		if err := newtrie.Validate(); err != nil {
+			return err
+		}
+	}
+	*trie = *newtrie
+	return nil
+}
+
+// newStreamReader returns binaryReader that reads data from r.
+func newStreamReader(r io.Reader) *binaryReader {
+	buffered := bufio.NewReader(r)
+	return &binaryReader{r: buffered, buffered: buffered}
+}
+
+func (writer *binaryWriter) Write(p []byte) (int, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	n, err := writer.w.Write(p)
+	writer.n += int64(n)
+	writer.err = err
+	return n, err
+}
+
+func (writer *binaryWriter) WriteString(s string) (int, error) {
+	if writer.err != nil {
+		return 0, writer.err
+	}
+	n, err := io.WriteString(writer.w, s)
+	writer.n += int64(n)
+	writer.err = err
+	return n, err
+}
+
+// fail holds err unless an error is already held.
+func (writer *binaryWriter) fail(err error) {
+	if writer.err == nil {
+		writer.err = err
+	}
+}
+
+func (writer *binaryWriter) write(p []byte) {
+	writer.Write(p)
+}
+
+func (writer *binaryWriter) writeString(s string) {
+	writer.WriteString(s)
+}
+
+func (writer *binaryWriter) writeUint32(v uint32) {
+	var buf [4]byte
+	binary.LittleEndian.PutUint32(buf[:], v)
+	writer.Write(buf[:])
+}
+
+func (writer *binaryWriter) writeUint64(v uint64) {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], v)
+	writer.Write(buf[:])
+}
+
+// writeSize32 writes size as uint32. If size doesn't fit uint32, ErrorTooLarge is held instead of writing truncated size.
+func (writer *binaryWriter) writeSize32(size uint64) {
+	if size > math.MaxUint32 {
+		writer.fail(ErrorTooLarge)
+		return
+	}
+	writer.writeUint32(uint32(size))
+}
+
+// writeContainerHeader writes magic number and version of binary format of Map or DynamicTrie.
+func (writer *binaryWriter) writeContainerHeader(magic string) {
+	writer.writeString(magic)
+	writer.writeUint32(containerFormatVersion)
+}
+
+// writeVector writes size of the bit vector and the bit vector.
+func (writer *binaryWriter) writeVector(vector sbvector.SuccinctBitVector) {
+	if writer.err != nil {
+		return
+	}
+	buf, err := vector.MarshalBinary()
+	if err != nil {
+		writer.err = err
+		return
+	}
+	writer.writeSize32(uint64(len(buf)))
+	writer.write(buf)
+}
+
+// writeVectorBinary writes the bit vector without its size.
+func (writer *binaryWriter) writeVectorBinary(vector sbvector.SuccinctBitVector) {
+	if writer.err != nil {
+		return
+	}
+	buf, err := vector.MarshalBinary()
+	if err != nil {
+		writer.err = err
+		return
+	}
+	writer.write(buf)
+}
+
+// writeSection writes tag and size of the payload, and the payload.
+func (writer *binaryWriter) writeSection(tag uint32, payload []byte) {
+	writer.writeUint32(tag)
+	writer.writeSize32(uint64(len(payload)))
+	writer.write(payload)
+}
+
+func (reader *binaryReader) Read(p []byte) (int, error) {
+	if reader.inMemory {
+		if reader.n >= int64(len(reader.data)) {
+			return 0, io.EOF
+		}
+		n := copy(p, reader.data[reader.n:])
+		reader.n += int64(n)
+		return n, nil
+	}
+	n, err := reader.r.Read(p)
+	reader.n += int64(n)
+	return n, err
+}
+
+// readFull reads exactly len(buf) bytes. If data ends before len(buf) bytes, this function returns ErrorInvalidFormat.
+func (reader *binaryReader) readFull(buf []byte) error {
+	_, err := io.ReadFull(reader, buf)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return ErrorInvalidFormat
+	}
+	return err
+}
+
+func (reader *binaryReader) readUint32() (uint32, error) {
+	var buf [4]byte
+	if err := reader.readFull(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), nil
+}
+
+func (reader *binaryReader) readUint64() (uint64, error) {
+	var buf [8]byte
+	if err := reader.readFull(buf[:]); err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint64(buf[:]), nil
+}
+
+/*
+readBytes reads size bytes.
+Memory is allocated as data is read, so broken size doesn't allocate large memory.
+*/
+func (reader *binaryReader) readBytes(size uint64) ([]byte, error) {
+	if reader.inMemory {
+		if uint64(len(reader.data))-uint64(reader.n) < size {
+			return nil, ErrorInvalidFormat
+		}
+		buf := reader.data[reader.n : reader.n+int64(size)]
+		reader.n += int64(size)
+		return buf, nil
+	}
+	buf := make([]byte, 0, min(size, readChunkSize))
+	for uint64(len(buf)) < size {
+		n := min(size-uint64(len(buf)), readChunkSize)
+		buf = slices.Grow(buf, int(n))
+		if err := reader.readFull(buf[len(buf) : len(buf)+int(n)]); err != nil {
+			return nil, err
+		}
+		buf = buf[:len(buf)+int(n)]
+	}
+	return buf, nil
+}
+
+// ownBytes returns buf that doesn't refer to data of the reader unless noCopy is true.
+func (reader *binaryReader) ownBytes(buf []byte) []byte {
+	if reader.inMemory && !reader.noCopy {
+		return slices.Clone(buf)
+	}
+	return buf
+}
+
+// ownString returns buf as string. If noCopy is true, the string refers to data of the reader.
+func (reader *binaryReader) ownString(buf []byte) string {
+	if reader.noCopy && len(buf) != 0 {
+		return unsafe.String(&buf[0], len(buf))
+	}
+	return string(buf)
+}
+
+// subReader returns binaryReader that reads next size bytes.
+func (reader *binaryReader) subReader(size uint64) (*binaryReader, error) {
+	if reader.inMemory {
+		buf, err := reader.readBytes(size)
+		if err != nil {
+			return nil, err
+		}
+		return &binaryReader{inMemory: true, data: buf, noCopy: reader.noCopy, skipChecksum: reader.skipChecksum}, nil
+	}
+	sub := newStreamReader(io.LimitReader(reader, int64(size)))
+	sub.skipChecksum = reader.skipChecksum
+	return sub, nil
+}
+
+// hasPrefix reports whether unread data starts with prefix. Data isn't consumed.
+func (reader *binaryReader) hasPrefix(prefix string) bool {
+	if reader.inMemory {
+		return bytes.HasPrefix(reader.data[min(reader.n, int64(len(reader.data))):], []byte(prefix))
+	}
+	if reader.buffered == nil {
+		return false
+	}
+	buf, err := reader.buffered.Peek(len(prefix))
+	return err == nil && string(buf) == prefix
+}
+
+// readContainerHeader reads magic number and version of binary format of Map or DynamicTrie.
+func (reader *binaryReader) readContainerHeader(magic string) error {
+	buf, err := reader.readBytes(uint64(len(magic)))
+	if err != nil {
+		return err
+	}
+	if string(buf) != magic {
+		return ErrorInvalidFormat
+	}
+	version, err := reader.readUint32()
+	if err != nil {
+		return err
+	}
+	if version != containerFormatVersion {
+		return ErrorUnsupportedVersion
+	}
+	return nil
+}
+
+// atEOF reports whether all data is read.
+func (reader *binaryReader) atEOF() (bool, error) {
+	if reader.inMemory {
+		return reader.n >= int64(len(reader.data)), nil
+	}
+	var buf [1]byte
+	_, err := io.ReadFull(reader, buf[:])
+	if err == io.EOF {
+		return true, nil
+	}
+	return false, err
+}
+
+// readVector reads size of the bit vector and the bit vector.
+func (reader *binaryReader) readVector() (sbvector.SuccinctBitVector, error) {
+	size, err := reader.readUint32()
+	if err != nil {
+		return nil, err
+	}
+	buf, err := reader.readBytes(uint64(size))
+	if err != nil {
+		return nil, err
+	}
+	vector, err := sbvector.NewVectorFromBinary(buf)
+	if err != nil {
+		return nil, ErrorInvalidFormat
+	}
+	return vector, nil
+}
+
+// readTag reads tag of an optional section. If data ends, second result parameter is false.
+func (reader *binaryReader) readTag() (uint32, bool, error) {
+	var buf [4]byte
+	_, err := io.ReadFull(reader, buf[:])
+	if err == io.EOF {
+		return 0, false, nil
+	}
+	if err == io.ErrUnexpectedEOF {
+		return 0, false, ErrorInvalidFormat
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return binary.LittleEndian.Uint32(buf[:]), true, nil
+}
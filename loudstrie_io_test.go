@@ -0,0 +1,114 @@
+package loudstrie
+
+import (
+	"bytes"
+	"errors"
+	"math"
+	"path/filepath"
+	"testing"
+	"testing/iotest"
+)
+
+func TestWriteToReadFrom(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	weights := make([]uint64, len(keyList))
+	for i := range weights {
+		weights[i] = uint64(i)
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	trie3, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, Weights: weights, LexicographicID: true})
+	trie3.(*TrieData).Delete(keyList[0])
+	tries := []*Trie{&trie1, &trie2, &trie3}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		bin, _ := trieData.MarshalBinary()
+		var buffer bytes.Buffer
+		n, err := trieData.WriteTo(&buffer)
+		if err != nil || n != int64(len(bin)) {
+			t.Error("WriteTo error", n, err)
+		}
+		if !bytes.Equal(buffer.Bytes(), bin) {
+			t.Error("WriteTo must write same data as MarshalBinary")
+		}
+
+		newTrie := new(TrieData)
+		n, err = newTrie.ReadFrom(iotest.OneByteReader(bytes.NewReader(bin)))
+		if err != nil || n != int64(len(bin)) {
+			t.Error("ReadFrom error", n, err)
+		}
+		if newTrie.GetNumOfKeys() != trieData.GetNumOfKeys() {
+			t.Error("Expected", trieData.GetNumOfKeys(), "got", newTrie.GetNumOfKeys())
+		}
+		for _, key := range keyList {
+			id1, found1 := trieData.ExactMatchSearch(key)
+			id2, found2 := newTrie.ExactMatchSearch(key)
+			if id1 != id2 || found1 != found2 {
+				t.Error("Expected", id1, "got", id2)
+			}
+		}
+
+		for _, i := range []int{0, 7, len(bin) / 2, len(bin) - 1} {
+			if _, err := new(TrieData).ReadFrom(bytes.NewReader(bin[:i])); !errors.Is(err, ErrorInvalidFormat) {
+				t.Error("Expected", ErrorInvalidFormat, "got", err)
+			}
+		}
+		readErr := errors.New("read error")
+		if _, err := new(TrieData).ReadFrom(iotest.ErrReader(readErr)); err != readErr {
+			t.Error("Expected", readErr, "got", err)
+		}
+		if _, err := trieData.WriteTo(&limitedWriter{limit: len(bin) / 2}); err != errShortWrite {
+			t.Error("Expected", errShortWrite, "got", err)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "trie.bin")
+	if err := trie1.(*TrieData).Save(path); err != nil {
+		t.Error(err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Error(err)
+	}
+	for _, key := range keyList {
+		if _, found := loaded.ExactMatchSearch(key); !found {
+			t.Error("Not found", key)
+		}
+	}
+	if _, err := Load(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Load must fail for missing file")
+	}
+}
+
+func TestWriteSize32(t *testing.T) {
+	var buffer bytes.Buffer
+	writer := &binaryWriter{w: &buffer}
+	writer.writeSize32(math.MaxUint32)
+	if writer.err != nil || buffer.Len() != 4 {
+		t.Error("writeSize32 error", writer.err)
+	}
+	writer.writeSize32(math.MaxUint32 + 1)
+	if writer.err != ErrorTooLarge {
+		t.Error("Expected", ErrorTooLarge, "got", writer.err)
+	}
+	if buffer.Len() != 4 {
+		t.Error("Truncated size must not be written")
+	}
+}
+
+var errShortWrite = errors.New("short write")
+
+type limitedWriter struct {
+	limit int
+}
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errShortWrite
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
@@ -0,0 +1,114 @@
+package loudstrie
+
+import (
+	"iter"
+	"sort"
+)
+
+/*
+KeyEnumerator is implemented by tries that enumerate keys in lexicographic order, such as *TrieData and *DynamicTrie.
+Trie doesn't include it, so callers type-assert a Trie to KeyEnumerator.
+*/
+type KeyEnumerator interface {
+	All() iter.Seq2[uint64, string]
+	AllWithPrefix(prefix string) iter.Seq2[uint64, string]
+}
+
+/*
+allKeys returns an iterator over keys of the trie in lexicographic order.
+If the trie isn't KeyEnumerator, keys are decoded by ID and sorted. IDs of such trie are assumed to be less than GetNumOfKeys.
+*/
+func allKeys(trie Trie) iter.Seq2[uint64, string] {
+	if enumerator, ok := trie.(KeyEnumerator); ok {
+		return enumerator.All()
+	}
+	return func(yield func(uint64, string) bool) {
+		var keys []KeyID
+		for id := uint64(0); id < numOfIDs(trie); id++ {
+			if key, found := trie.DecodeKey(id); found {
+				keys = append(keys, KeyID{key, id})
+			}
+		}
+		sort.Slice(keys, func(i, j int) bool { return keys[i].Key < keys[j].Key })
+		for _, item := range keys {
+			if !yield(item.ID, item.Key) {
+				return
+			}
+		}
+	}
+}
+
+/*
+All returns an iterator over all keys in lexicographic order.
+The iterator yields ID and key string.
+*/
+func (trie *TrieData) All() iter.Seq2[uint64, string] {
+	return trie.AllWithPrefix("")
+}
+
+/*
+AllWithPrefix returns an iterator over keys starting with prefix in lexicographic order.
+The iterator yields ID and key string.
+*/
+func (trie *TrieData) AllWithPrefix(prefix string) iter.Seq2[uint64, string] {
+	return func(yield func(uint64, string) bool) {
+		pos, zeros, depth, found := searchPrefix(trie, prefix)
+		if !found {
+			return
+		}
+		trie.walkKeys(pos, zeros, []byte(prefix[:depth]), func(id uint64, key []byte) bool {
+			return yield(id, string(key))
+		})
+	}
+}
+
+/*
+PrefixesOf returns an iterator over keys that are prefixes of text in ascending order of length.
+The iterator yields ID and key string.
+*/
+func (trie *TrieData) PrefixesOf(text string) iter.Seq2[uint64, string] {
+	return func(yield func(uint64, string) bool) {
+		nodePos := uint64(0)
+		zeros := uint64(0)
+		keyPos := uint64(0)
+		keyLen := uint64(len(text))
+		for {
+			id, canTraverse := trie.Traverse(text, keyLen, &nodePos, &zeros, &keyPos)
+			if id != NotFound {
+				if !yield(id, text[:keyPos-1]) {
+					return
+				}
+			}
+			if !canTraverse {
+				return
+			}
+		}
+	}
+}
+
+/*
+walkKeys enumerates keys in the subtree of the node in lexicographic order.
+key holds the path from the root to the node, and it is extended while descending the subtree.
+This function returns false if yield returns false.
+*/
+func (trie *TrieData) walkKeys(pos uint64, zeros uint64, key []byte, yield func(uint64, []byte) bool) bool {
+	ones := pos - zeros
+	if ok, _ := trie.tail.Get(ones); ok {
+		tailID, _ := trie.tail.Rank1(ones)
+		key = append(key, trie.getTail(tailID)...)
+	}
+	if id, found := trie.terminalID(ones); found {
+		if !yield(id, key) {
+			return false
+		}
+	}
+	for i := uint64(0); !trie.isLeaf(pos + i); i++ {
+		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
+		nextPos++
+		nextKey := append(key, trie.edges[zeros+i-uint64(2)])
+		if !trie.walkKeys(nextPos, nextPos-zeros-i+uint64(1), nextKey, yield) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,128 @@
+package loudstrie
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestIterators(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		var keys []string
+		for id, key := range trieData.All() {
+			if decode, _ := (*trie).DecodeKey(id); decode != key {
+				t.Error("Expected", decode, "got", key)
+			}
+			keys = append(keys, key)
+		}
+		if strings.Join(keys, ",") != "abc,abcde,able,bbc,can" {
+			t.Error(keys)
+		}
+
+		keys = nil
+		for _, key := range trieData.AllWithPrefix("ab") {
+			keys = append(keys, key)
+			if key == "abcde" {
+				break
+			}
+		}
+		if strings.Join(keys, ",") != "abc,abcde" {
+			t.Error(keys)
+		}
+
+		keys = nil
+		for _, key := range trieData.AllWithPrefix("bb") {
+			keys = append(keys, key)
+		}
+		if strings.Join(keys, ",") != "bbc" {
+			t.Error(keys)
+		}
+		for _, key := range trieData.AllWithPrefix("bbcd") {
+			t.Error("Unexpected key", key)
+		}
+
+		keys = nil
+		for id, key := range trieData.PrefixesOf("abcdef") {
+			if decode, _ := (*trie).DecodeKey(id); decode != key {
+				t.Error("Expected", decode, "got", key)
+			}
+			keys = append(keys, key)
+		}
+		if strings.Join(keys, ",") != "abc,abcde" {
+			t.Error(keys)
+		}
+		for _, key := range trieData.PrefixesOf("abcdef") {
+			if key != "abc" {
+				t.Error("Expected abc got", key)
+			}
+			break
+		}
+	}
+
+	keyList2 := genKeyList(3000, 30)
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	sort.Strings(keyList2)
+	keyList2 = removeDuplicates(keyList2)
+	for _, trie := range tries2 {
+		trieData := (*trie).(*TrieData)
+		i := 0
+		for _, key := range trieData.All() {
+			if i >= len(keyList2) || keyList2[i] != key {
+				t.Error("Unexpected key", key)
+				break
+			}
+			i++
+		}
+		if i != len(keyList2) {
+			t.Error("Expected", len(keyList2), "got", i)
+		}
+	}
+}
+
+// plainTrie exposes only methods of Trie, as an implementation outside of this package.
+type plainTrie struct {
+	Trie
+}
+
+func TestAllKeysOfPlainTrie(t *testing.T) {
+	keyList := genKeyList(300, 10)
+	trie, _ := NewTrie(keyList, true)
+	if _, ok := Trie(plainTrie{trie}).(KeyEnumerator); ok {
+		t.Fatal("plainTrie must not be KeyEnumerator")
+	}
+	var expected []string
+	for _, key := range trie.(*TrieData).All() {
+		expected = append(expected, key)
+	}
+	i := 0
+	for id, key := range allKeys(plainTrie{trie}) {
+		if i >= len(expected) || key != expected[i] {
+			t.Fatal("Expected", expected, "got", key)
+		}
+		if decode, _ := trie.DecodeKey(id); decode != key {
+			t.Error("Expected", key, "got", decode)
+		}
+		i++
+	}
+	if i != len(expected) {
+		t.Error("Expected", len(expected), "got", i)
+	}
+	union, err := Union(plainTrie{trie}, trie)
+	if err != nil || union.GetNumOfKeys() != uint64(len(expected)) {
+		t.Error("Union error", err)
+	}
+}
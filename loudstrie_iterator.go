@@ -0,0 +1,300 @@
+package loudstrie
+
+/*
+Iterator iterates keys of LOUDS Trie in lexicographic order.
+
+Iterator is invalid until one of First, Last, Seek, LowerBound or UpperBound is called.
+*/
+type Iterator struct {
+	trie  *TrieData
+	stack []iteratorFrame
+	path  []byte
+	valid bool
+}
+
+// iteratorFrame holds a node on the path from the root to the current node.
+type iteratorFrame struct {
+	pos   uint64
+	zeros uint64
+	// depth is length of the path from the root to the node.
+	depth int
+	// child is index of the child that is on the path.
+	child uint64
+}
+
+/*
+NewIterator returns new Iterator of the trie.
+*/
+func (trie *TrieData) NewIterator() *Iterator {
+	return &Iterator{trie: trie}
+}
+
+/*
+Valid returns true if the iterator points a key.
+*/
+func (it *Iterator) Valid() bool {
+	return it.valid
+}
+
+/*
+Key returns key string that the iterator points.
+*/
+func (it *Iterator) Key() string {
+	if !it.valid {
+		return ""
+	}
+	top := it.stack[len(it.stack)-1]
+	key := string(it.path[:top.depth])
+	ones := top.pos - top.zeros
+	if ok, _ := it.trie.tail.Get(ones); ok {
+		tailID, _ := it.trie.tail.Rank1(ones)
+		key += it.trie.getTail(tailID)
+	}
+	return key
+}
+
+/*
+ID returns ID of the key that the iterator points.
+If the iterator is invalid, this function returns `loudstrie.NotFound`.
+*/
+func (it *Iterator) ID() uint64 {
+	if !it.valid {
+		return NotFound
+	}
+	top := it.stack[len(it.stack)-1]
+	id, _ := it.trie.terminalID(top.pos - top.zeros)
+	return id
+}
+
+/*
+First moves the iterator to the smallest key.
+*/
+func (it *Iterator) First() bool {
+	it.reset()
+	return it.settleForward()
+}
+
+/*
+Last moves the iterator to the largest key.
+*/
+func (it *Iterator) Last() bool {
+	it.reset()
+	it.descendLast()
+	return it.settleBackward()
+}
+
+/*
+Seek moves the iterator to the smallest key that is greater than or equal to the query string.
+*/
+func (it *Iterator) Seek(key string) bool {
+	it.reset()
+	keyLen := len(key)
+	for i := 0; ; i++ {
+		top := &it.stack[len(it.stack)-1]
+		ones := top.pos - top.zeros
+		if ok, _ := it.trie.tail.Get(ones); ok {
+			// The node holds only one key that is key[:i] + TAIL.
+			tailID, _ := it.trie.tail.Rank1(ones)
+			if key[i:] <= it.trie.getTail(tailID) {
+				return it.settleForward()
+			}
+			if !it.skipSubtree() {
+				return false
+			}
+			return it.settleForward()
+		}
+		if i == keyLen {
+			return it.settleForward()
+		}
+		j := uint64(0)
+		for ; !it.trie.isLeaf(top.pos + j); j++ {
+			if it.trie.edges[top.zeros+j-uint64(2)] >= key[i] {
+				break
+			}
+		}
+		if it.trie.isLeaf(top.pos + j) {
+			if !it.skipSubtree() {
+				return false
+			}
+			return it.settleForward()
+		}
+		edge := it.pushChild(j)
+		if edge > key[i] {
+			return it.settleForward()
+		}
+	}
+}
+
+/*
+LowerBound moves the iterator to the smallest key that is greater than or equal to the query string.
+This function is same as Seek.
+*/
+func (it *Iterator) LowerBound(key string) bool {
+	return it.Seek(key)
+}
+
+/*
+UpperBound moves the iterator to the smallest key that is greater than the query string.
+If the query string is in the trie, the iterator points the next key of it.
+*/
+func (it *Iterator) UpperBound(key string) bool {
+	if !it.Seek(key) {
+		return false
+	}
+	if it.Key() == key {
+		return it.Next()
+	}
+	return true
+}
+
+/*
+Next moves the iterator to the next key.
+*/
+func (it *Iterator) Next() bool {
+	if !it.valid {
+		return false
+	}
+	if !it.nextNode() {
+		return false
+	}
+	return it.settleForward()
+}
+
+/*
+Prev moves the iterator to the previous key.
+*/
+func (it *Iterator) Prev() bool {
+	if !it.valid {
+		return false
+	}
+	if !it.prevNode() {
+		return false
+	}
+	return it.settleBackward()
+}
+
+/*
+RangeSearch searches keys that are greater than or equal to lo and less than hi.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+*/
+func (trie *TrieData) RangeSearch(lo string, hi string, limit uint64) []KeyID {
+	var res []KeyID
+	if limit == 0 {
+		limit = noLimit
+	}
+	it := trie.NewIterator()
+	for ok := it.Seek(lo); ok && uint64(len(res)) < limit; ok = it.Next() {
+		key := it.Key()
+		if key >= hi {
+			break
+		}
+		res = append(res, KeyID{key, it.ID()})
+	}
+	return res
+}
+
+func (it *Iterator) reset() {
+	it.stack = append(it.stack[:0], iteratorFrame{pos: uint64(2), zeros: uint64(2)})
+	it.path = it.path[:0]
+	it.valid = true
+}
+
+func (it *Iterator) isTerminal() bool {
+	top := it.stack[len(it.stack)-1]
+	_, found := it.trie.terminalID(top.pos - top.zeros)
+	return found
+}
+
+// settleForward moves the iterator forward until it points a key.
+func (it *Iterator) settleForward() bool {
+	for !it.isTerminal() {
+		if !it.nextNode() {
+			return false
+		}
+	}
+	return true
+}
+
+// settleBackward moves the iterator backward until it points a key.
+func (it *Iterator) settleBackward() bool {
+	for !it.isTerminal() {
+		if !it.prevNode() {
+			return false
+		}
+	}
+	return true
+}
+
+func (it *Iterator) numOfChildren(pos uint64) uint64 {
+	n := uint64(0)
+	for !it.trie.isLeaf(pos + n) {
+		n++
+	}
+	return n
+}
+
+// pushChild moves the iterator to i-th child of the current node, and returns label of the edge.
+func (it *Iterator) pushChild(i uint64) byte {
+	top := &it.stack[len(it.stack)-1]
+	top.child = i
+	c := it.trie.edges[top.zeros+i-uint64(2)]
+	nextPos, _ := it.trie.louds.Select1(top.zeros + i - uint64(1))
+	nextPos++
+	nextZeros := nextPos - top.zeros - i + uint64(1)
+	it.path = append(it.path[:top.depth], c)
+	it.stack = append(it.stack, iteratorFrame{pos: nextPos, zeros: nextZeros, depth: len(it.path)})
+	return c
+}
+
+// nextNode moves the iterator to the next node in pre-order.
+func (it *Iterator) nextNode() bool {
+	top := it.stack[len(it.stack)-1]
+	if !it.trie.isLeaf(top.pos) {
+		it.pushChild(0)
+		return true
+	}
+	return it.skipSubtree()
+}
+
+// skipSubtree moves the iterator to the next node of the subtree of the current node in pre-order.
+func (it *Iterator) skipSubtree() bool {
+	for {
+		it.stack = it.stack[:len(it.stack)-1]
+		if len(it.stack) == 0 {
+			it.valid = false
+			return false
+		}
+		parent := it.stack[len(it.stack)-1]
+		if !it.trie.isLeaf(parent.pos + parent.child + uint64(1)) {
+			it.pushChild(parent.child + uint64(1))
+			return true
+		}
+	}
+}
+
+// prevNode moves the iterator to the previous node in pre-order.
+func (it *Iterator) prevNode() bool {
+	it.stack = it.stack[:len(it.stack)-1]
+	if len(it.stack) == 0 {
+		it.valid = false
+		return false
+	}
+	parent := it.stack[len(it.stack)-1]
+	if parent.child > 0 {
+		it.pushChild(parent.child - uint64(1))
+		it.descendLast()
+	}
+	return true
+}
+
+// descendLast moves the iterator to the last node of the subtree of the current node in pre-order.
+func (it *Iterator) descendLast() {
+	for {
+		top := it.stack[len(it.stack)-1]
+		n := it.numOfChildren(top.pos)
+		if n == 0 {
+			return
+		}
+		it.pushChild(n - uint64(1))
+	}
+}
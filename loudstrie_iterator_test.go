@@ -0,0 +1,147 @@
+package loudstrie
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestIterator(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	sortedKeyList := []string{"abc", "abcde", "able", "bbc", "can"}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		it := trieData.NewIterator()
+		if it.Valid() || it.Next() || it.Prev() || it.ID() != NotFound {
+			t.Error("Iterator must be invalid before positioning.")
+		}
+
+		i := 0
+		for ok := it.First(); ok; ok = it.Next() {
+			if it.Key() != sortedKeyList[i] {
+				t.Error("Expected", sortedKeyList[i], "got", it.Key())
+			}
+			if id, _ := (*trie).ExactMatchSearch(it.Key()); id != it.ID() {
+				t.Error("Expected", id, "got", it.ID())
+			}
+			i++
+		}
+		if i != len(sortedKeyList) || it.Valid() {
+			t.Error("Expected", len(sortedKeyList), "got", i)
+		}
+
+		i = len(sortedKeyList) - 1
+		for ok := it.Last(); ok; ok = it.Prev() {
+			if it.Key() != sortedKeyList[i] {
+				t.Error("Expected", sortedKeyList[i], "got", it.Key())
+			}
+			i--
+		}
+		if i != -1 {
+			t.Error("Expected -1 got", i)
+		}
+
+		seeks := map[string]string{
+			"":      "abc",
+			"abc":   "abc",
+			"abcd":  "abcde",
+			"abcdf": "able",
+			"abl":   "able",
+			"ablf":  "bbc",
+			"bbb":   "bbc",
+			"c":     "can",
+			"can":   "can",
+		}
+		for key, expected := range seeks {
+			if !it.Seek(key) || it.Key() != expected {
+				t.Error("Expected", expected, "got", it.Key(), key)
+			}
+		}
+		for _, key := range []string{"cana", "d", "\xff"} {
+			if it.Seek(key) {
+				t.Error("Seek error for key that is larger than all keys.", key, it.Key())
+			}
+		}
+
+		bounds := []struct {
+			key   string
+			lower string
+			upper string
+		}{
+			{"", "abc", "abc"},
+			{"abc", "abc", "abcde"},
+			{"abcd", "abcde", "abcde"},
+			{"abcde", "abcde", "able"},
+			{"able", "able", "bbc"},
+			{"bbb", "bbc", "bbc"},
+			{"can", "can", ""},
+			{"cana", "", ""},
+		}
+		for _, bound := range bounds {
+			if ok := it.LowerBound(bound.key); ok != (bound.lower != "") || it.Key() != bound.lower {
+				t.Error("LowerBound: expected", bound.lower, "got", it.Key(), bound.key)
+			}
+			if ok := it.UpperBound(bound.key); ok != (bound.upper != "") || it.Key() != bound.upper {
+				t.Error("UpperBound: expected", bound.upper, "got", it.Key(), bound.key)
+			}
+		}
+
+		if !it.Seek("abd") || !it.Prev() || it.Key() != "abcde" || !it.Next() || !it.Next() || it.Key() != "bbc" {
+			t.Error("Seek error", it.Key())
+		}
+
+		results := trieData.RangeSearch("abcd", "bbc", 0)
+		if len(results) != 2 || results[0].Key != "abcde" || results[1].Key != "able" {
+			t.Error(results)
+		}
+		results = trieData.RangeSearch("abcd", "bbc", 1)
+		if len(results) != 1 {
+			t.Error(results)
+		}
+		results = trieData.RangeSearch("", "\xff", 0)
+		if len(results) != len(sortedKeyList) {
+			t.Error(results)
+		}
+		results = trieData.RangeSearch("c", "a", 0)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+	}
+
+	keyList2 := genKeyList(3000, 20)
+	trie3, _ := NewTrie(keyList2, true)
+	sort.Strings(keyList2)
+	keyList2 = removeDuplicates(keyList2)
+	it := trie3.(*TrieData).NewIterator()
+	for i, key := range keyList2 {
+		if !it.Seek(key) || it.Key() != key {
+			t.Error("Expected", key, "got", it.Key())
+		}
+		query := key + "\x00"
+		j := sort.SearchStrings(keyList2, query)
+		if it.Seek(query) != (j < len(keyList2)) || (j < len(keyList2) && it.Key() != keyList2[j]) {
+			t.Error("Expected", j, "got", it.Key(), query)
+		}
+		if i > 0 {
+			it.Seek(key)
+			if !it.Prev() || it.Key() != keyList2[i-1] {
+				t.Error("Expected", keyList2[i-1], "got", it.Key())
+			}
+		}
+	}
+
+	trie4, _ := NewTrie([]string{}, true)
+	it = trie4.(*TrieData).NewIterator()
+	if it.First() || it.Last() || it.Seek("") {
+		t.Error("Iterator error for empty trie.")
+	}
+}
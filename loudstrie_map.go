@@ -0,0 +1,228 @@
+package loudstrie
+
+import (
+	"bytes"
+	"errors"
+)
+
+/*
+ValueCodec is interface of the codec that serializes values of Map.
+*/
+type ValueCodec[V any] interface {
+	EncodeValue(value V) ([]byte, error)
+	DecodeValue(data []byte) (V, error)
+}
+
+/*
+Map holds LOUDS Trie and values that aligned to IDs of the keys.
+*/
+type Map[V any] struct {
+	trie   Trie
+	values []V
+	codec  ValueCodec[V]
+}
+
+/*
+MapResult holds result of common-prefix search of Map.
+*/
+type MapResult[V any] struct {
+	// ID of the key.
+	ID uint64
+	// Length of the key string.
+	Length uint64
+	// Value of the key.
+	Value V
+}
+
+/*
+MapEntry holds result of predictive search of Map.
+*/
+type MapEntry[V any] struct {
+	// ID of the key.
+	ID uint64
+	// Value of the key.
+	Value V
+}
+
+var (
+	// ErrorNoValueCodec indicates that value codec is not specified.
+	ErrorNoValueCodec = errors.New("Map: value codec is not specified")
+)
+
+/*
+NewMap returns new Map that holds keys and values of valueMap.
+
+codec is used by MarshalBinary and UnmarshalBinary. It may be nil if the Map is never serialized.
+*/
+func NewMap[V any](valueMap map[string]V, useTailTrie bool, codec ValueCodec[V]) (*Map[V], error) {
+	keyList := make([]string, 0, len(valueMap))
+	for key := range valueMap {
+		keyList = append(keyList, key)
+	}
+	trie, err := NewTrie(keyList, useTailTrie)
+	if err != nil {
+		return nil, err
+	}
+	m := &Map[V]{trie: trie, codec: codec}
+	m.values = make([]V, trie.GetNumOfKeys())
+	for key, value := range valueMap {
+		id, _ := trie.ExactMatchSearch(key)
+		m.values[id] = value
+	}
+	return m, nil
+}
+
+/*
+NewMapFromBinary returns new Map that initialize by binary data.
+*/
+func NewMapFromBinary[V any](binData []byte, codec ValueCodec[V]) (*Map[V], error) {
+	m := &Map[V]{codec: codec}
+	err := m.UnmarshalBinary(binData)
+	return m, err
+}
+
+/*
+Trie returns LOUDS Trie that holds keys of the Map.
+*/
+func (m *Map[V]) Trie() Trie {
+	return m.trie
+}
+
+/*
+Get returns value of the key.
+If couldn't find the key, value of second result parameter is false.
+*/
+func (m *Map[V]) Get(key string) (V, bool) {
+	id, found := m.trie.ExactMatchSearch(key)
+	if !found {
+		var zero V
+		return zero, false
+	}
+	return m.values[id], true
+}
+
+/*
+GetByID returns value corresponding to the ID.
+If the ID doesn't exist or its key is deleted, value of second result parameter is false.
+*/
+func (m *Map[V]) GetByID(id uint64) (V, bool) {
+	if id >= uint64(len(m.values)) || !containsID(m.trie, id) {
+		var zero V
+		return zero, false
+	}
+	return m.values[id], true
+}
+
+/*
+CommonPrefixSearch looks up keys from the possible prefixes of a query string.
+This function returns slice of `MapResult`.
+*/
+func (m *Map[V]) CommonPrefixSearch(key string, limit uint64) []MapResult[V] {
+	var res []MapResult[V]
+	for _, item := range m.trie.CommonPrefixSearch(key, limit) {
+		res = append(res, MapResult[V]{item.ID, item.Length, m.values[item.ID]})
+	}
+	return res
+}
+
+/*
+PredictiveSearch searches keys starting with a query string.
+This function returns slice of `MapEntry`.
+*/
+func (m *Map[V]) PredictiveSearch(key string, limit uint64) []MapEntry[V] {
+	var res []MapEntry[V]
+	for _, id := range m.trie.PredictiveSearch(key, limit) {
+		res = append(res, MapEntry[V]{id, m.values[id]})
+	}
+	return res
+}
+
+/*
+MarshalBinary implements the encoding.BinaryMarshaler interface.
+Binary data starts with a magic number and a format version, and sizes are 64-bit.
+*/
+func (m *Map[V]) MarshalBinary() ([]byte, error) {
+	if m.codec == nil {
+		return nil, ErrorNoValueCodec
+	}
+	buffer := new(bytes.Buffer)
+	writer := &binaryWriter{w: buffer}
+	writer.writeContainerHeader(mapFormatMagic)
+
+	// trie
+	buf, err := m.trie.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	writer.writeUint64(uint64(len(buf)))
+	writer.write(buf)
+
+	// values
+	writer.writeUint64(uint64(len(m.values)))
+	for _, value := range m.values {
+		buf, err = m.codec.EncodeValue(value)
+		if err != nil {
+			return nil, err
+		}
+		writer.writeUint64(uint64(len(buf)))
+		writer.write(buf)
+	}
+	if writer.err != nil {
+		return nil, writer.err
+	}
+	return buffer.Bytes(), nil
+}
+
+/*
+UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+*/
+func (m *Map[V]) UnmarshalBinary(data []byte) error {
+	if m.codec == nil {
+		return ErrorNoValueCodec
+	}
+	reader := &binaryReader{inMemory: true, data: data}
+	if err := reader.readContainerHeader(mapFormatMagic); err != nil {
+		return err
+	}
+
+	trieSize, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	buf, err := reader.readBytes(trieSize)
+	if err != nil {
+		return err
+	}
+	trie, err := NewTrieFromBinary(buf)
+	if err != nil {
+		return err
+	}
+
+	numOfValues, err := reader.readUint64()
+	if err != nil {
+		return err
+	}
+	if numOfValues != numOfIDs(trie) {
+		return ErrorInvalidFormat
+	}
+
+	values := make([]V, numOfValues)
+	for i := range values {
+		valueSize, err := reader.readUint64()
+		if err != nil {
+			return err
+		}
+		buf, err := reader.readBytes(valueSize)
+		if err != nil {
+			return err
+		}
+		values[i], err = m.codec.DecodeValue(buf)
+		if err != nil {
+			return err
+		}
+	}
+
+	m.trie = trie
+	m.values = values
+	return nil
+}
@@ -0,0 +1,125 @@
+package loudstrie
+
+import (
+	"encoding/binary"
+	"errors"
+	"testing"
+)
+
+type uint64Codec struct{}
+
+func (uint64Codec) EncodeValue(value uint64) ([]byte, error) {
+	buf := make([]byte, 8)
+	binary.LittleEndian.PutUint64(buf, value)
+	return buf, nil
+}
+
+func (uint64Codec) DecodeValue(data []byte) (uint64, error) {
+	if len(data) != 8 {
+		return 0, errors.New("invalid value")
+	}
+	return binary.LittleEndian.Uint64(data), nil
+}
+
+func TestMap(t *testing.T) {
+	valueMap := map[string]uint64{
+		"bbc":   1,
+		"able":  2,
+		"abc":   3,
+		"abcde": 4,
+		"can":   5,
+	}
+	for _, useTailTrie := range []bool{true, false} {
+		m, err := NewMap[uint64](valueMap, useTailTrie, uint64Codec{})
+		if err != nil {
+			t.Error("Build error")
+			continue
+		}
+		for key, value := range valueMap {
+			got, found := m.Get(key)
+			if !found || got != value {
+				t.Error("Expected", value, "got", got, key)
+			}
+		}
+		if _, found := m.Get("ab"); found {
+			t.Error("Search error for key that does not exist in the map.")
+		}
+
+		results := m.CommonPrefixSearch("abcde", 0)
+		if len(results) != 2 || results[0].Value != 3 || results[0].Length != 3 || results[1].Value != 4 {
+			t.Error(results)
+		}
+
+		entries := m.PredictiveSearch("ab", 0)
+		if len(entries) != 3 {
+			t.Error(entries)
+		}
+		for _, entry := range entries {
+			key, _ := m.Trie().DecodeKey(entry.ID)
+			if valueMap[key] != entry.Value {
+				t.Error("Expected", valueMap[key], "got", entry.Value, key)
+			}
+		}
+
+		buf, err := m.MarshalBinary()
+		if err != nil {
+			t.Error(err)
+		}
+		newMap, err := NewMapFromBinary[uint64](buf, uint64Codec{})
+		if err != nil {
+			t.Error(err)
+		}
+		for key, value := range valueMap {
+			got, found := newMap.Get(key)
+			if !found || got != value {
+				t.Error("Expected", value, "got", got, key)
+			}
+		}
+
+		for i := 1; i < len(buf)-1; i++ {
+			if _, err = NewMapFromBinary[uint64](buf[0:i], uint64Codec{}); err == nil {
+				t.Error("Truncated binary was accepted", i)
+			}
+		}
+	}
+
+	// Size near the limit of uint64 must not overflow offset.
+	hostile := binary.LittleEndian.AppendUint32([]byte(mapFormatMagic), containerFormatVersion)
+	hostile = binary.LittleEndian.AppendUint64(hostile, 0xfffffffffffffffe)
+	if _, err := NewMapFromBinary[uint64](hostile, uint64Codec{}); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+	unsupported := binary.LittleEndian.AppendUint32([]byte(mapFormatMagic), containerFormatVersion+1)
+	if _, err := NewMapFromBinary[uint64](unsupported, uint64Codec{}); err != ErrorUnsupportedVersion {
+		t.Error("Expected", ErrorUnsupportedVersion, "got", err)
+	}
+
+	// Data without the magic number is rejected.
+	if _, err := NewMapFromBinary[uint64]([]byte{0xfe, 0xff, 0xff, 0xff, 0, 0, 0, 0}, uint64Codec{}); err != ErrorInvalidFormat {
+		t.Error("Expected", ErrorInvalidFormat, "got", err)
+	}
+
+	m, _ := NewMap[uint64](valueMap, false, nil)
+	if _, err := m.MarshalBinary(); err != ErrorNoValueCodec {
+		t.Error(err)
+	}
+
+	// Values of deleted keys aren't returned.
+	id, _ := m.Trie().ExactMatchSearch("abc")
+	if got, found := m.GetByID(id); !found || got != valueMap["abc"] {
+		t.Error("Expected", valueMap["abc"], "got", got)
+	}
+	dynamic, _ := NewDynamicTrie(m.Trie())
+	dynamicMap := &Map[uint64]{trie: dynamic, values: m.values}
+	dynamic.Delete("abc")
+	if _, found := dynamicMap.GetByID(id); found {
+		t.Error("Value of deleted key is found")
+	}
+	m.Trie().(*TrieData).Delete("abc")
+	if _, found := m.GetByID(id); found {
+		t.Error("Value of deleted key is found")
+	}
+	if _, found := m.GetByID(uint64(len(valueMap))); found {
+		t.Error("Value of ID that does not exist is found")
+	}
+}
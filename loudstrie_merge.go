@@ -0,0 +1,138 @@
+package loudstrie
+
+import (
+	"container/heap"
+	"iter"
+)
+
+/*
+IDMapping maps IDs of a source trie to IDs of the merged trie.
+IDMapping[id] is the new ID of the key that has id in the source trie. IDs of deleted keys are mapped to `loudstrie.NotFound`.
+*/
+type IDMapping []uint64
+
+// mergeCursor holds the current key of a source trie.
+type mergeCursor struct {
+	index int
+	id    uint64
+	key   string
+	next  func() (uint64, string, bool)
+}
+
+type mergeQueue []*mergeCursor
+
+func (q mergeQueue) Len() int { return len(q) }
+
+func (q mergeQueue) Less(i, j int) bool {
+	if q[i].key != q[j].key {
+		return q[i].key < q[j].key
+	}
+	return q[i].index < q[j].index
+}
+
+func (q mergeQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *mergeQueue) Push(x interface{}) { *q = append(*q, x.(*mergeCursor)) }
+
+func (q *mergeQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+// mergeSource holds a source trie and ID of a key in the trie.
+type mergeSource struct {
+	index int
+	id    uint64
+}
+
+/*
+Merge merges keys of the tries into a new trie.
+
+Keys are enumerated from the tries in lexicographic order and merged without decoding each key by ID.
+This function returns the merged trie and IDMapping for each source trie. The i-th IDMapping corresponds to tries[i].
+
+If the first trie is *TrieData, the merged trie is built with its UseTailTrie and LexicographicID options.
+If a source trie has weights, the merged trie has weights, and the largest weight is used for the key in several tries.
+*/
+func Merge(tries ...Trie) (Trie, []IDMapping, error) {
+	options := TrieOptions{}
+	hasWeights := false
+	for i, trie := range tries {
+		trieData, ok := trie.(*TrieData)
+		if !ok {
+			continue
+		}
+		if i == 0 {
+			options.UseTailTrie = trieData.hasTailTrie
+			options.LexicographicID = trieData.hasLexID
+		}
+		hasWeights = hasWeights || trieData.hasWeights
+	}
+
+	mappings := make([]IDMapping, len(tries))
+	q := &mergeQueue{}
+	for i, trie := range tries {
+		mappings[i] = make(IDMapping, numOfIDs(trie))
+		for id := range mappings[i] {
+			mappings[i][id] = NotFound
+		}
+		next, stop := iter.Pull2(allKeys(trie))
+		defer stop()
+		cursor := &mergeCursor{index: i, next: next}
+		if cursor.advance() {
+			heap.Push(q, cursor)
+		}
+	}
+
+	keyList := []string{}
+	var weights []uint64
+	var sources [][]mergeSource
+	for q.Len() > 0 {
+		cursor := (*q)[0]
+		source := mergeSource{cursor.index, cursor.id}
+		if len(keyList) == 0 || keyList[len(keyList)-1] != cursor.key {
+			keyList = append(keyList, cursor.key)
+			sources = append(sources, nil)
+			if hasWeights {
+				weights = append(weights, 0)
+			}
+		}
+		last := len(keyList) - 1
+		sources[last] = append(sources[last], source)
+		if hasWeights {
+			if trieData, ok := tries[cursor.index].(*TrieData); ok {
+				weights[last] = max(weights[last], trieData.weightOf(cursor.id))
+			}
+		}
+		if cursor.advance() {
+			heap.Fix(q, 0)
+		} else {
+			heap.Pop(q)
+		}
+	}
+
+	options.Weights = weights
+	// keyList is already sorted and has no duplicated keys.
+	merged, err := newTrieFromSortedKeys(keyList, options)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The merged trie enumerates keys in same order as keyList.
+	i := 0
+	for newID := range allKeys(merged) {
+		for _, source := range sources[i] {
+			mappings[source.index][source.id] = newID
+		}
+		i++
+	}
+	return merged, mappings, nil
+}
+
+func (cursor *mergeCursor) advance() bool {
+	id, key, ok := cursor.next()
+	cursor.id = id
+	cursor.key = key
+	return ok
+}
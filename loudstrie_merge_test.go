@@ -0,0 +1,72 @@
+package loudstrie
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestMerge(t *testing.T) {
+	keyList := genKeyList(1000, 10)
+	sort.Strings(keyList)
+	keyList = removeDuplicates(keyList)
+	keyLists := [][]string{keyList[:400], keyList[300:700], keyList[600:]}
+	var tries []Trie
+	for i, keys := range keyLists {
+		trie, _ := NewTrie(keys, i%2 == 0)
+		tries = append(tries, trie)
+	}
+	deletedID, _ := tries[1].ExactMatchSearch(keyList[350])
+	tries[1].(*TrieData).DeleteID(deletedID)
+
+	merged, mappings, err := Merge(tries...)
+	if err != nil {
+		t.Error(err)
+	}
+	if merged.GetNumOfKeys() != uint64(len(keyList)) {
+		t.Error("Expected", len(keyList), "got", merged.GetNumOfKeys())
+	}
+	for i, trie := range tries {
+		if uint64(len(mappings[i])) != uint64(len(keyLists[i])) {
+			t.Error("Expected", len(keyLists[i]), "got", len(mappings[i]))
+		}
+		for _, key := range keyLists[i] {
+			oldID, found := trie.ExactMatchSearch(key)
+			if !found {
+				continue
+			}
+			newID, _ := merged.ExactMatchSearch(key)
+			if mappings[i][oldID] != newID {
+				t.Error("Expected", newID, "got", mappings[i][oldID])
+			}
+		}
+	}
+	if mappings[1][deletedID] != NotFound {
+		t.Error("Deleted key must not be mapped", mappings[1][deletedID])
+	}
+
+	trie1, _ := NewTrieWithWeights([]string{"abc", "abd"}, []uint64{1, 5}, false)
+	trie2, _ := NewTrieWithWeights([]string{"abc", "abe"}, []uint64{10, 2}, false)
+	merged, _, _ = Merge(trie1, trie2)
+	res := merged.(*TrieData).TopKPredictive("ab", 3)
+	expected := []string{"abc", "abd", "abe"}
+	for i, item := range res {
+		key, _ := merged.DecodeKey(item.ID)
+		if key != expected[i] {
+			t.Error("Expected", expected[i], "got", key)
+		}
+	}
+
+	merged, mappings, err = Merge()
+	if err != nil || merged.GetNumOfKeys() != 0 || len(mappings) != 0 {
+		t.Error("Merge error for no tries.")
+	}
+
+	i := 0
+	merged, _, _ = Merge(tries...)
+	for _, key := range merged.(*TrieData).All() {
+		if key != keyList[i] {
+			t.Error("Expected", keyList[i], "got", key)
+		}
+		i++
+	}
+}
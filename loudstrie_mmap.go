@@ -0,0 +1,78 @@
+package loudstrie
+
+import (
+	"os"
+)
+
+/*
+MappedTrie is LOUDS Trie that refers to a memory-mapped file.
+
+Edges and TAIL strings of the trie refer to the mapped region directly, and their pages are shared between processes through the page cache.
+Bit vectors are restored by go-sbvector, which holds its own copy of them on the heap, so loading still takes time and memory proportional to size of the bit vectors.
+*/
+type MappedTrie struct {
+	trie *TrieData
+	data []byte
+}
+
+/*
+NewTrieFromBytesNoCopy returns new Trie that initialize by binary data without copying edges and TAIL strings.
+data must not be modified while the trie is used.
+
+Checksums of sections are verified and the trie is validated by Validate.
+Bit vectors are copied regardless of them, so loading takes O(n) time even if they are skipped by NewTrieFromBinaryWithOptions with SkipValidation.
+*/
+func NewTrieFromBytesNoCopy(data []byte) (Trie, error) {
+	return NewTrieFromBinaryWithOptions(data, LoadOptions{NoCopy: true})
+}
+
+/*
+Mmap maps the file written by Save or MarshalBinary into memory, and returns MappedTrie that refers to it.
+The trie must not be used after Close is called.
+
+Checksums of sections are verified and the trie is validated by Validate, so all pages of the file are read.
+MmapWithOptions with SkipValidation skips them for trusted files, but bit vectors are still copied to the heap as described in MappedTrie.
+*/
+func Mmap(path string) (*MappedTrie, error) {
+	return MmapWithOptions(path, LoadOptions{})
+}
+
+/*
+MmapWithOptions is same as Mmap except that the trie is loaded with options.
+The trie always refers to the mapped region regardless of options.NoCopy.
+*/
+func MmapWithOptions(path string, options LoadOptions) (*MappedTrie, error) {
+	options.NoCopy = true
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	data, err := mmapFile(file)
+	if err != nil {
+		return nil, err
+	}
+	trie, err := NewTrieFromBinaryWithOptions(data, options)
+	if err != nil {
+		munmap(data)
+		return nil, err
+	}
+	return &MappedTrie{trie: trie.(*TrieData), data: data}, nil
+}
+
+/*
+Trie returns the trie that refers to the mapped file.
+*/
+func (mapped *MappedTrie) Trie() Trie {
+	return mapped.trie
+}
+
+/*
+Close unmaps the file.
+*/
+func (mapped *MappedTrie) Close() error {
+	data := mapped.data
+	mapped.data = nil
+	mapped.trie = nil
+	return munmap(data)
+}
@@ -0,0 +1,17 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package loudstrie
+
+import (
+	"io"
+	"os"
+)
+
+// mmapFile reads whole of the file on platforms that don't support mmap.
+func mmapFile(file *os.File) ([]byte, error) {
+	return io.ReadAll(file)
+}
+
+func munmap(data []byte) error {
+	return nil
+}
@@ -0,0 +1,76 @@
+package loudstrie
+
+import (
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestMmap(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		path := filepath.Join(t.TempDir(), "trie.bin")
+		if err := (*trie).(*TrieData).Save(path); err != nil {
+			t.Error(err)
+			continue
+		}
+		mapped, err := Mmap(path)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		for _, key := range keyList {
+			id1, _ := (*trie).ExactMatchSearch(key)
+			id2, found := mapped.Trie().ExactMatchSearch(key)
+			if !found || id1 != id2 {
+				t.Error("Expected", id1, "got", id2)
+			}
+			decode, _ := mapped.Trie().DecodeKey(id2)
+			if decode != key {
+				t.Error("Expected", key, "got", decode)
+			}
+		}
+		if err := mapped.Close(); err != nil {
+			t.Error(err)
+		}
+		mapped, err = MmapWithOptions(path, LoadOptions{SkipValidation: true})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if id, found := mapped.Trie().ExactMatchSearch(keyList[0]); !found || id == NotFound {
+			t.Error("Not found", keyList[0])
+		}
+		if err := mapped.Close(); err != nil {
+			t.Error(err)
+		}
+
+		bin, _ := (*trie).MarshalBinary()
+		newTrie, err := NewTrieFromBytesNoCopy(bin)
+		if err != nil {
+			t.Error(err)
+		}
+		edges := newTrie.(*TrieData).edges
+		start := uintptr(unsafe.Pointer(&bin[0]))
+		edgesPtr := uintptr(unsafe.Pointer(&edges[0]))
+		if edgesPtr < start || edgesPtr >= start+uintptr(len(bin)) {
+			t.Error("Edges must refer to binary data")
+		}
+		for _, key := range keyList {
+			if _, found := newTrie.ExactMatchSearch(key); !found {
+				t.Error("Not found", key)
+			}
+		}
+		if _, err := NewTrieFromBytesNoCopy(bin[:len(bin)-1]); err != ErrorInvalidFormat {
+			t.Error("Expected", ErrorInvalidFormat, "got", err)
+		}
+	}
+
+	if _, err := Mmap(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Mmap must fail for missing file")
+	}
+}
@@ -0,0 +1,259 @@
+package loudstrie
+
+import (
+	"errors"
+)
+
+var (
+	// ErrorBadPattern indicates that pattern is malformed.
+	ErrorBadPattern = errors.New("PatternSearch: syntax error in pattern")
+)
+
+const (
+	globLiteral = iota
+	globAny
+	globStar
+	globClass
+)
+
+type globToken struct {
+	kind  int
+	c     byte
+	class [4]uint64
+}
+
+/*
+globPattern is compiled glob pattern.
+State i of the pattern means that tokens[:i] are matched.
+*/
+type globPattern struct {
+	tokens []globToken
+}
+
+type patternSearcher struct {
+	trie    *TrieData
+	pattern *globPattern
+	limit   uint64
+	// states[d] is set of states for the path of depth d.
+	states [][]bool
+	path   []byte
+	res    []KeyID
+}
+
+/*
+PatternSearch searches keys matching a glob pattern.
+
+The pattern syntax is:
+
+	'*'         matches any sequence of bytes
+	'?'         matches any single byte
+	'[' [ '!' | '^' ] { range } ']'
+	            matches a byte in ranges (or not in ranges)
+	range       c or lo '-' hi
+	'\\' c      matches byte c
+
+The pattern is matched against whole of the key, byte by byte.
+This function returns slice of `KeyID` in lexicographic order of the keys.
+*/
+func (trie *TrieData) PatternSearch(pattern string, limit uint64) ([]KeyID, error) {
+	glob, err := compileGlob(pattern)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = noLimit
+	}
+	searcher := &patternSearcher{
+		trie:    trie,
+		pattern: glob,
+		limit:   limit,
+	}
+	root := searcher.getStates(0)
+	root[0] = true
+	glob.closure(root)
+	searcher.search(uint64(2), uint64(2), 0)
+	return searcher.res, nil
+}
+
+func compileGlob(pattern string) (*globPattern, error) {
+	glob := &globPattern{}
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*':
+			glob.tokens = append(glob.tokens, globToken{kind: globStar})
+		case '?':
+			glob.tokens = append(glob.tokens, globToken{kind: globAny})
+		case '\\':
+			i++
+			if i == len(pattern) {
+				return nil, ErrorBadPattern
+			}
+			glob.tokens = append(glob.tokens, globToken{kind: globLiteral, c: pattern[i]})
+		case '[':
+			token := globToken{kind: globClass}
+			i++
+			negate := false
+			if i < len(pattern) && (pattern[i] == '!' || pattern[i] == '^') {
+				negate = true
+				i++
+			}
+			for first := true; ; first = false {
+				if i == len(pattern) {
+					return nil, ErrorBadPattern
+				}
+				if pattern[i] == ']' && !first {
+					break
+				}
+				lo, next, err := globClassChar(pattern, i)
+				if err != nil {
+					return nil, err
+				}
+				hi := lo
+				i = next
+				if i+1 < len(pattern) && pattern[i] == '-' && pattern[i+1] != ']' {
+					hi, next, err = globClassChar(pattern, i+1)
+					if err != nil {
+						return nil, err
+					}
+					if hi < lo {
+						return nil, ErrorBadPattern
+					}
+					i = next
+				}
+				for c := int(lo); c <= int(hi); c++ {
+					token.class[c>>6] |= uint64(1) << uint(c&63)
+				}
+			}
+			if negate {
+				for j := range token.class {
+					token.class[j] = ^token.class[j]
+				}
+			}
+			glob.tokens = append(glob.tokens, token)
+		default:
+			glob.tokens = append(glob.tokens, globToken{kind: globLiteral, c: pattern[i]})
+		}
+	}
+	return glob, nil
+}
+
+// globClassChar reads a byte in character class, and returns the byte and index of the next character.
+func globClassChar(pattern string, i int) (byte, int, error) {
+	if pattern[i] == '\\' {
+		i++
+		if i == len(pattern) {
+			return 0, 0, ErrorBadPattern
+		}
+	}
+	return pattern[i], i + 1, nil
+}
+
+func (glob *globPattern) numOfStates() int {
+	return len(glob.tokens) + 1
+}
+
+// closure adds states that can be reached without consuming a byte.
+func (glob *globPattern) closure(states []bool) {
+	for i, token := range glob.tokens {
+		if states[i] && token.kind == globStar {
+			states[i+1] = true
+		}
+	}
+}
+
+// step computes states after consuming c, and returns false if next states are empty.
+func (glob *globPattern) step(states []bool, c byte, next []bool) bool {
+	found := false
+	for i := range next {
+		next[i] = false
+	}
+	for i, token := range glob.tokens {
+		if !states[i] {
+			continue
+		}
+		switch token.kind {
+		case globLiteral:
+			if token.c != c {
+				continue
+			}
+			next[i+1] = true
+		case globAny:
+			next[i+1] = true
+		case globStar:
+			next[i] = true
+		case globClass:
+			if token.class[c>>6]&(uint64(1)<<uint(c&63)) == 0 {
+				continue
+			}
+			next[i+1] = true
+		}
+		found = true
+	}
+	glob.closure(next)
+	return found
+}
+
+func (glob *globPattern) accepts(states []bool) bool {
+	return states[len(glob.tokens)]
+}
+
+func (searcher *patternSearcher) getStates(depth int) []bool {
+	for len(searcher.states) <= depth {
+		searcher.states = append(searcher.states, make([]bool, searcher.pattern.numOfStates()))
+	}
+	return searcher.states[depth]
+}
+
+func (searcher *patternSearcher) step(depth int, c byte) bool {
+	searcher.path = append(searcher.path[:depth], c)
+	states := searcher.getStates(depth)
+	return searcher.pattern.step(states, c, searcher.getStates(depth+1))
+}
+
+// search walks the subtree of the node, and returns false if number of results reaches limit.
+func (searcher *patternSearcher) search(pos uint64, zeros uint64, depth int) bool {
+	trie := searcher.trie
+	ones := pos - zeros
+	if ok, _ := trie.tail.Get(ones); ok {
+		tailID, _ := trie.tail.Rank1(ones)
+		tail := trie.getTail(tailID)
+		for i := 0; i < len(tail); i++ {
+			if !searcher.step(depth, tail[i]) {
+				return true
+			}
+			depth++
+		}
+	}
+	if id, found := trie.terminalID(ones); found && searcher.pattern.accepts(searcher.states[depth]) {
+		searcher.res = append(searcher.res, KeyID{string(searcher.path[:depth]), id})
+		if uint64(len(searcher.res)) >= searcher.limit {
+			return false
+		}
+	}
+	for i := uint64(0); !trie.isLeaf(pos + i); i++ {
+		if !searcher.step(depth, trie.edges[zeros+i-uint64(2)]) {
+			continue
+		}
+		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
+		nextPos++
+		if !searcher.search(nextPos, nextPos-zeros-i+uint64(1), depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns true if whole of the key matches the pattern.
+func (glob *globPattern) match(key string) bool {
+	states := make([]bool, glob.numOfStates())
+	next := make([]bool, glob.numOfStates())
+	states[0] = true
+	glob.closure(states)
+	for i := 0; i < len(key); i++ {
+		if !glob.step(states, key[i], next) {
+			return false
+		}
+		states, next = next, states
+	}
+	return glob.accepts(states)
+}
@@ -0,0 +1,94 @@
+package loudstrie
+
+import (
+	"path"
+	"testing"
+)
+
+func TestPatternSearch(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+		"a*c",
+		"A-100",
+		"A-205",
+		"B-100",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		patterns := map[string]int{
+			"ab?":      1,
+			"ab*":      3,
+			"*c":       3,
+			"a\\*c":    1,
+			"*":        9,
+			"":         0,
+			"[AB]-1*":  2,
+			"A-[0-1]*": 1,
+			"A-[!1]*":  1,
+			"[^a-z]*":  3,
+			"?b?":      2,
+			"ab?d*":    1,
+			"can?":     0,
+			"*e":       2,
+			"a[-*]c":   1,
+			"[]a]b?":   1,
+		}
+		for pattern, expected := range patterns {
+			results, err := trieData.PatternSearch(pattern, 0)
+			if err != nil {
+				t.Error(err)
+			}
+			if len(results) != expected {
+				t.Error("Expected", expected, "got", results, pattern)
+			}
+			for _, item := range results {
+				if id, _ := (*trie).ExactMatchSearch(item.Key); id != item.ID {
+					t.Error("Expected", id, "got", item.ID)
+				}
+			}
+		}
+		results, _ := trieData.PatternSearch("*", 2)
+		if len(results) != 2 || results[0].Key != "A-100" || results[1].Key != "A-205" {
+			t.Error(results)
+		}
+		for _, pattern := range []string{"[a", "a\\", "[z-a]", "[", "[a-"} {
+			if _, err := trieData.PatternSearch(pattern, 0); err != ErrorBadPattern {
+				t.Error("Expected ErrorBadPattern got", err, pattern)
+			}
+		}
+	}
+
+	keyList2 := genKeyList(3000, 6)
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	keyList2 = removeDuplicates(keyList2)
+	for _, trie := range tries2 {
+		trieData := (*trie).(*TrieData)
+		for _, pattern := range []string{"a*", "*Z", "?[0-9]*", "*a*b*", "[^a-z]?", "??", "*[xyz]?"} {
+			expected := 0
+			for _, key := range keyList2 {
+				if ok, _ := path.Match(pattern, key); ok {
+					expected++
+				}
+			}
+			results, _ := trieData.PatternSearch(pattern, 0)
+			if len(results) != expected {
+				t.Error("Expected", expected, "got", len(results), pattern)
+			}
+			for _, item := range results {
+				if ok, _ := path.Match(pattern, item.Key); !ok {
+					t.Error("Unexpected key", item.Key, pattern)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package loudstrie
+
+import (
+	"sort"
+	"testing"
+)
+
+func TestLexicographicID(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	sortedKeyList := []string{"abc", "abcde", "able", "bbc", "can"}
+	trie1, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, LexicographicID: true})
+	trie2, _ := NewTrieWithOptions(keyList, TrieOptions{LexicographicID: true})
+	bin, _ := trie1.MarshalBinary()
+	trie3, err := NewTrieFromBinary(bin)
+	if err != nil {
+		t.Error(err)
+	}
+	tries := []*Trie{&trie1, &trie2, &trie3}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		for i, key := range sortedKeyList {
+			id, found := (*trie).ExactMatchSearch(key)
+			if !found || id != uint64(i) {
+				t.Error("Expected", i, "got", id, key)
+			}
+			decode, found := trieData.Select(uint64(i))
+			if !found || decode != key {
+				t.Error("Expected", key, "got", decode)
+			}
+			rank, found := trieData.Rank(key)
+			if !found || rank != uint64(i) {
+				t.Error("Expected", i, "got", rank, key)
+			}
+		}
+		if _, found := trieData.Select(uint64(len(sortedKeyList))); found {
+			t.Error("Select error for index that does not exist in the trie.")
+		}
+
+		ranks := map[string]uint64{
+			"":        0,
+			"a":       0,
+			"abcd":    1,
+			"abcdf":   2,
+			"abd":     2,
+			"ablf":    3,
+			"b":       3,
+			"bbb":     3,
+			"bbcc":    4,
+			"c":       4,
+			"cana":    5,
+			"d":       5,
+			"\xff":    5,
+			"abc\x00": 1,
+		}
+		for key, expected := range ranks {
+			rank, _ := trieData.Rank(key)
+			if rank != expected {
+				t.Error("Expected", expected, "got", rank, key)
+			}
+		}
+
+		results := (*trie).CommonPrefixSearch("abcde", 0)
+		if len(results) != 2 || results[0].ID != 0 || results[1].ID != 1 {
+			t.Error(results)
+		}
+		ids := (*trie).PredictiveSearch("ab", 0)
+		if len(ids) != 3 || ids[0] != 0 || ids[1] != 1 || ids[2] != 2 {
+			t.Error(ids)
+		}
+	}
+
+	trie4, _ := NewTrie(keyList, false)
+	if _, found := trie4.(*TrieData).Rank("abc"); found {
+		t.Error("Rank error for trie without lexicographic IDs.")
+	}
+	if _, found := trie4.(*TrieData).Select(0); found {
+		t.Error("Select error for trie without lexicographic IDs.")
+	}
+
+	keyList2 := genKeyList(3000, 20)
+	newTrie, _ := NewTrieWithOptions(keyList2, TrieOptions{UseTailTrie: true, LexicographicID: true})
+	trie5 := newTrie.(*TrieData)
+	sort.Strings(keyList2)
+	keyList2 = removeDuplicates(keyList2)
+	for i, key := range keyList2 {
+		if id, _ := trie5.ExactMatchSearch(key); id != uint64(i) {
+			t.Error("Expected", i, "got", id, key)
+		}
+		if rank, _ := trie5.Rank(key); rank != uint64(i) {
+			t.Error("Expected", i, "got", rank, key)
+		}
+		nextKey := key + "\x00"
+		if rank, _ := trie5.Rank(nextKey); rank != uint64(sort.SearchStrings(keyList2, nextKey)) {
+			t.Error("Expected", sort.SearchStrings(keyList2, nextKey), "got", rank, nextKey)
+		}
+		prevKey := key[:len(key)-1]
+		if rank, _ := trie5.Rank(prevKey); rank != uint64(sort.SearchStrings(keyList2, prevKey)) {
+			t.Error("Expected", sort.SearchStrings(keyList2, prevKey), "got", rank, prevKey)
+		}
+	}
+	var liveKeys []string
+	for i, key := range keyList2 {
+		if i%3 != 1 {
+			liveKeys = append(liveKeys, key)
+		}
+	}
+	// Delete keys in descending order, so that they aren't deleted in order of IDs.
+	for i := len(keyList2) - 1; i >= 0; i-- {
+		if i%3 == 1 {
+			trie5.Delete(keyList2[i])
+		}
+	}
+	bin, err = trie5.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trie6, err := NewTrieFromBinary(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trie := range []*TrieData{trie5, trie6.(*TrieData)} {
+		for i, key := range keyList2 {
+			expected := uint64(sort.SearchStrings(liveKeys, key))
+			if rank, _ := trie.Rank(key); rank != expected {
+				t.Error("Expected", expected, "got", rank, key)
+			}
+			if i < len(liveKeys) {
+				if decode, found := trie.Select(uint64(i)); !found || decode != liveKeys[i] {
+					t.Error("Expected", liveKeys[i], "got", decode, i)
+				}
+			}
+		}
+		if _, found := trie.Select(uint64(len(liveKeys))); found {
+			t.Error("Select error for index that does not exist in the trie.")
+		}
+	}
+}
@@ -0,0 +1,248 @@
+package loudstrie
+
+import (
+	"regexp/syntax"
+	"unicode/utf8"
+)
+
+/*
+regexpState is state of the automaton for a path of the trie.
+*/
+type regexpState struct {
+	// threads holds instructions waiting for next rune.
+	threads []uint32
+	// prev is the last rune. -1 indicates beginning of text.
+	prev rune
+	// pending holds bytes of incomplete UTF-8 sequence.
+	pending  [utf8.UTFMax]byte
+	nPending int
+	// matched indicates that a match was found in the path, so all keys in the subtree match.
+	matched bool
+}
+
+/*
+regexpMatcher runs the program of regular expression as NFA over bytes.
+*/
+type regexpMatcher struct {
+	prog     *syntax.Prog
+	anchored bool
+	visited  []uint32
+	gen      uint32
+	closure  []uint32
+}
+
+type regexpSearcher struct {
+	trie    *TrieData
+	matcher *regexpMatcher
+	limit   uint64
+	// states[d] is state of the automaton for the path of depth d.
+	states []regexpState
+	res    []uint64
+}
+
+/*
+RegexpSearch searches keys matching a regular expression.
+
+The syntax of the expression is same as the regexp package.
+As regexp.MatchString, a key matches if any substring of the key matches the expression, so use ^ and $ to match whole of the key.
+Invalid UTF-8 bytes in the keys are treated as U+FFFD.
+This function returns slice of ID in lexicographic order of the keys.
+*/
+func (trie *TrieData) RegexpSearch(expr string, limit uint64) ([]uint64, error) {
+	matcher, err := compileRegexp(expr)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = noLimit
+	}
+	searcher := &regexpSearcher{
+		trie:    trie,
+		matcher: matcher,
+		limit:   limit,
+	}
+	searcher.states = append(searcher.states, regexpState{prev: -1})
+	searcher.search(uint64(2), uint64(2), 0)
+	return searcher.res, nil
+}
+
+func compileRegexp(expr string) (*regexpMatcher, error) {
+	re, err := syntax.Parse(expr, syntax.Perl)
+	if err != nil {
+		return nil, err
+	}
+	prog, err := syntax.Compile(re.Simplify())
+	if err != nil {
+		return nil, err
+	}
+	matcher := &regexpMatcher{
+		prog:     prog,
+		anchored: prog.StartCond()&syntax.EmptyBeginText != 0,
+		visited:  make([]uint32, len(prog.Inst)),
+	}
+	return matcher, nil
+}
+
+// addThread follows the instructions that don't consume a rune, and collects the instructions that consume a rune.
+func (matcher *regexpMatcher) addThread(pc uint32, flag syntax.EmptyOp) {
+	if matcher.visited[pc] == matcher.gen {
+		return
+	}
+	matcher.visited[pc] = matcher.gen
+	inst := &matcher.prog.Inst[pc]
+	switch inst.Op {
+	case syntax.InstAlt, syntax.InstAltMatch:
+		matcher.addThread(inst.Out, flag)
+		matcher.addThread(inst.Arg, flag)
+	case syntax.InstCapture, syntax.InstNop:
+		matcher.addThread(inst.Out, flag)
+	case syntax.InstEmptyWidth:
+		if syntax.EmptyOp(inst.Arg)&^flag == 0 {
+			matcher.addThread(inst.Out, flag)
+		}
+	case syntax.InstMatch, syntax.InstRune, syntax.InstRune1, syntax.InstRuneAny, syntax.InstRuneAnyNotNL:
+		matcher.closure = append(matcher.closure, pc)
+	}
+}
+
+// computeClosure computes the instructions reachable from the threads at the position between prev and next.
+// This function returns true if the closure contains a match.
+func (matcher *regexpMatcher) computeClosure(state *regexpState, next rune) bool {
+	matcher.gen++
+	if matcher.gen == 0 {
+		for i := range matcher.visited {
+			matcher.visited[i] = 0
+		}
+		matcher.gen++
+	}
+	matcher.closure = matcher.closure[:0]
+	flag := syntax.EmptyOpContext(state.prev, next)
+	for _, pc := range state.threads {
+		matcher.addThread(pc, flag)
+	}
+	if !matcher.anchored || state.prev == -1 {
+		matcher.addThread(uint32(matcher.prog.Start), flag)
+	}
+	for _, pc := range matcher.closure {
+		if matcher.prog.Inst[pc].Op == syntax.InstMatch {
+			return true
+		}
+	}
+	return false
+}
+
+// stepRune updates the state by consuming r.
+func (matcher *regexpMatcher) stepRune(state *regexpState, r rune) {
+	if state.matched {
+		return
+	}
+	if matcher.computeClosure(state, r) {
+		state.matched = true
+		return
+	}
+	state.threads = state.threads[:0]
+	for _, pc := range matcher.closure {
+		inst := &matcher.prog.Inst[pc]
+		if inst.Op != syntax.InstMatch && inst.MatchRune(r) {
+			state.threads = append(state.threads, inst.Out)
+		}
+	}
+	state.prev = r
+}
+
+// stepByte updates the state by consuming c. Runes are decoded from bytes before they are consumed.
+func (matcher *regexpMatcher) stepByte(state *regexpState, c byte) {
+	state.pending[state.nPending] = c
+	state.nPending++
+	for state.nPending > 0 && utf8.FullRune(state.pending[:state.nPending]) {
+		r, size := utf8.DecodeRune(state.pending[:state.nPending])
+		matcher.stepRune(state, r)
+		copy(state.pending[:], state.pending[size:state.nPending])
+		state.nPending -= size
+	}
+}
+
+// isDead returns true if no extension of the path can match.
+func (matcher *regexpMatcher) isDead(state *regexpState) bool {
+	return !state.matched && matcher.anchored && len(state.threads) == 0 && state.nPending == 0 && state.prev != -1
+}
+
+// matchEnd returns true if the path matches as whole of a key.
+func (matcher *regexpMatcher) matchEnd(state *regexpState) bool {
+	if state.matched {
+		return true
+	}
+	end := regexpState{
+		threads: append([]uint32(nil), state.threads...),
+		prev:    state.prev,
+	}
+	// Incomplete UTF-8 sequence at end of the key is decoded as U+FFFD for each byte.
+	for i := 0; i < state.nPending; i++ {
+		matcher.stepRune(&end, utf8.RuneError)
+	}
+	if end.matched {
+		return true
+	}
+	return matcher.computeClosure(&end, -1)
+}
+
+// step computes the state for the path extended by c, and returns false if no key in the subtree can match.
+func (searcher *regexpSearcher) step(depth int, c byte) bool {
+	for len(searcher.states) <= depth+1 {
+		searcher.states = append(searcher.states, regexpState{})
+	}
+	prev := &searcher.states[depth]
+	next := &searcher.states[depth+1]
+	next.threads = append(next.threads[:0], prev.threads...)
+	next.prev = prev.prev
+	next.pending = prev.pending
+	next.nPending = prev.nPending
+	next.matched = prev.matched
+	searcher.matcher.stepByte(next, c)
+	return !searcher.matcher.isDead(next)
+}
+
+// search walks the subtree of the node, and returns false if number of results reaches limit.
+func (searcher *regexpSearcher) search(pos uint64, zeros uint64, depth int) bool {
+	trie := searcher.trie
+	ones := pos - zeros
+	if ok, _ := trie.tail.Get(ones); ok {
+		tailID, _ := trie.tail.Rank1(ones)
+		tail := trie.getTail(tailID)
+		for i := 0; i < len(tail); i++ {
+			if !searcher.step(depth, tail[i]) {
+				return true
+			}
+			depth++
+		}
+	}
+	if id, found := trie.terminalID(ones); found && searcher.matcher.matchEnd(&searcher.states[depth]) {
+		searcher.res = append(searcher.res, id)
+		if uint64(len(searcher.res)) >= searcher.limit {
+			return false
+		}
+	}
+	for i := uint64(0); !trie.isLeaf(pos + i); i++ {
+		if !searcher.step(depth, trie.edges[zeros+i-uint64(2)]) {
+			continue
+		}
+		nextPos, _ := trie.louds.Select1(zeros + i - uint64(1))
+		nextPos++
+		if !searcher.search(nextPos, nextPos-zeros-i+uint64(1), depth+1) {
+			return false
+		}
+	}
+	return true
+}
+
+// match returns true if the key matches the expression.
+func (matcher *regexpMatcher) match(key string) bool {
+	state := regexpState{prev: -1}
+	for i := 0; i < len(key); i++ {
+		matcher.stepByte(&state, key[i])
+		if matcher.isDead(&state) {
+			return false
+		}
+	}
+	return matcher.matchEnd(&state)
+}
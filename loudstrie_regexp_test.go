@@ -0,0 +1,104 @@
+package loudstrie
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestRegexpSearch(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+		"A-100",
+		"A-205",
+		"B-100",
+		"日本語",
+		"日本",
+		"\xe6\x97",
+		"\xff\xfe",
+		"line1\nline2",
+	}
+	trie1, _ := NewTrie(keyList, false)
+	trie2, _ := NewTrie(keyList, true)
+	tries := []*Trie{&trie1, &trie2}
+	exprs := []string{
+		"^ab",
+		"c$",
+		"^ab.*e$",
+		"b",
+		"^[A-B]-1",
+		"\\d{3}",
+		"^.{2}$",
+		"^.{3}$",
+		"本",
+		"^\\x{FFFD}",
+		"^\\x{FFFD}{2}$",
+		"(?m)^line2$",
+		"^line2$",
+		"\\bcan\\b",
+		"(?i)^ABC",
+		"^$",
+		"x",
+		"",
+		"a|c",
+	}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		for _, expr := range exprs {
+			re := regexp.MustCompile(expr)
+			expected := make(map[string]bool)
+			for _, key := range keyList {
+				if re.MatchString(key) {
+					expected[key] = true
+				}
+			}
+			ids, err := trieData.RegexpSearch(expr, 0)
+			if err != nil {
+				t.Error(err)
+			}
+			if len(ids) != len(expected) {
+				t.Error("Expected", len(expected), "got", len(ids), expr)
+			}
+			for _, id := range ids {
+				key, _ := (*trie).DecodeKey(id)
+				if !expected[key] {
+					t.Error("Unexpected key", key, expr)
+				}
+			}
+		}
+		ids, _ := trieData.RegexpSearch("^ab", 2)
+		if len(ids) != 2 {
+			t.Error(ids)
+		}
+		if _, err := trieData.RegexpSearch("(", 0); err == nil {
+			t.Error("Error is expected for invalid expression.")
+		}
+	}
+
+	keyList2 := genKeyList(3000, 10)
+	trie3, _ := NewTrie(keyList2, true)
+	keyList2 = removeDuplicates(keyList2)
+	for _, expr := range []string{"^a", "Z$", "^[0-9]+[a-z]", "abc", "^.{3}$", "(ab|cd)[^e]"} {
+		re := regexp.MustCompile(expr)
+		expected := 0
+		for _, key := range keyList2 {
+			if re.MatchString(key) {
+				expected++
+			}
+		}
+		ids, _ := trie3.(*TrieData).RegexpSearch(expr, 0)
+		if len(ids) != expected {
+			t.Error("Expected", expected, "got", len(ids), expr)
+		}
+		for _, id := range ids {
+			key, _ := trie3.DecodeKey(id)
+			if !re.MatchString(key) {
+				t.Error("Unexpected key", key, expr)
+			}
+		}
+	}
+}
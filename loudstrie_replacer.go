@@ -0,0 +1,184 @@
+package loudstrie
+
+import (
+	"errors"
+	"io"
+	"strings"
+)
+
+/*
+Replacer replaces keys of LOUDS Trie in text with replacement strings.
+
+Replacer performs leftmost-longest matching. At each position of the text, the longest key is replaced.
+The other behaviors are same as strings.Replacer. Empty key matches at every position where no other key matches,
+and replacements are performed without overlapping matches.
+*/
+type Replacer struct {
+	values *Map[string]
+}
+
+/*
+StringCodec is ValueCodec for string values.
+*/
+type StringCodec struct{}
+
+var (
+	// ErrorInvalidReplacements indicates that number of replacements does not match number of keys.
+	ErrorInvalidReplacements = errors.New("NewReplacer: number of replacements does not match number of keys")
+)
+
+/*
+EncodeValue implements ValueCodec interface.
+*/
+func (StringCodec) EncodeValue(value string) ([]byte, error) {
+	return []byte(value), nil
+}
+
+/*
+DecodeValue implements ValueCodec interface.
+*/
+func (StringCodec) DecodeValue(data []byte) (string, error) {
+	return string(data), nil
+}
+
+/*
+NewReplacer returns new Replacer. replacements[id] is replacement of the key of the ID.
+*/
+func NewReplacer(trie Trie, replacements []string) (*Replacer, error) {
+	if uint64(len(replacements)) != numOfIDs(trie) {
+		return nil, ErrorInvalidReplacements
+	}
+	values := make([]string, len(replacements))
+	copy(values, replacements)
+	return &Replacer{&Map[string]{trie: trie, values: values, codec: StringCodec{}}}, nil
+}
+
+/*
+NewReplacerFromPairs returns new Replacer from a list of old, new string pairs as strings.NewReplacer.
+If an old string appears more than once, the first pair is used.
+*/
+func NewReplacerFromPairs(useTailTrie bool, oldnew ...string) (*Replacer, error) {
+	if len(oldnew)%2 == 1 {
+		return nil, ErrorInvalidReplacements
+	}
+	valueMap := make(map[string]string, len(oldnew)/2)
+	for i := 0; i < len(oldnew); i += 2 {
+		if _, ok := valueMap[oldnew[i]]; !ok {
+			valueMap[oldnew[i]] = oldnew[i+1]
+		}
+	}
+	values, err := NewMap[string](valueMap, useTailTrie, StringCodec{})
+	if err != nil {
+		return nil, err
+	}
+	return &Replacer{values}, nil
+}
+
+/*
+NewReplacerFromBinary returns new Replacer that initialize by binary data.
+*/
+func NewReplacerFromBinary(binData []byte) (*Replacer, error) {
+	replacer := new(Replacer)
+	err := replacer.UnmarshalBinary(binData)
+	return replacer, err
+}
+
+/*
+Replace returns a copy of s with all replacements performed.
+*/
+func (replacer *Replacer) Replace(s string) string {
+	var buf strings.Builder
+	replacer.WriteString(&buf, s)
+	return buf.String()
+}
+
+/*
+WriteString writes s to w with all replacements performed.
+*/
+func (replacer *Replacer) WriteString(w io.Writer, s string) (int, error) {
+	n := 0
+	last := 0
+	prevMatchEmpty := false
+	for i := 0; i <= len(s); {
+		id, length := replacer.longestMatch(s[i:])
+		// Ignore the empty match if the previous match is also empty.
+		if id == NotFound || (length == 0 && prevMatchEmpty) {
+			prevMatchEmpty = false
+			i++
+			continue
+		}
+		prevMatchEmpty = length == 0
+		wn, err := io.WriteString(w, s[last:i])
+		n += wn
+		if err != nil {
+			return n, err
+		}
+		wn, err = io.WriteString(w, replacer.values.values[id])
+		n += wn
+		if err != nil {
+			return n, err
+		}
+		i += length
+		last = i
+	}
+	if last != len(s) {
+		wn, err := io.WriteString(w, s[last:])
+		n += wn
+		return n, err
+	}
+	return n, nil
+}
+
+// longestMatch returns ID and length of the longest key that is a prefix of s. If no key matches, the ID is NotFound.
+func (replacer *Replacer) longestMatch(s string) (uint64, int) {
+	id := NotFound
+	length := 0
+	nodePos := uint64(0)
+	zeros := uint64(0)
+	keyPos := uint64(0)
+	keyLen := uint64(len(s))
+	for canTraverse := true; canTraverse; {
+		matchID := NotFound
+		// Tries of this package are called directly, so that the traversal state doesn't escape to heap.
+		switch trie := replacer.values.trie.(type) {
+		case *TrieData:
+			matchID, canTraverse = trie.Traverse(s, keyLen, &nodePos, &zeros, &keyPos)
+		case *DynamicTrie:
+			matchID, canTraverse = trie.Traverse(s, keyLen, &nodePos, &zeros, &keyPos)
+		default:
+			matchID, canTraverse = traverseCopy(trie, s, keyLen, &nodePos, &zeros, &keyPos)
+		}
+		if matchID != NotFound {
+			id = matchID
+			length = int(keyPos) - 1
+		}
+	}
+	return id, length
+}
+
+// traverseCopy calls Traverse of the trie with copies of the traversal state.
+func traverseCopy(trie Trie, key string, keyLen uint64, nodePos *uint64, zeros *uint64, keyPos *uint64) (uint64, bool) {
+	pos, z, k := *nodePos, *zeros, *keyPos
+	id, canTraverse := trie.Traverse(key, keyLen, &pos, &z, &k)
+	*nodePos, *zeros, *keyPos = pos, z, k
+	return id, canTraverse
+}
+
+/*
+MarshalBinary implements the encoding.BinaryMarshaler interface.
+*/
+func (replacer *Replacer) MarshalBinary() ([]byte, error) {
+	return replacer.values.MarshalBinary()
+}
+
+/*
+UnmarshalBinary implements the encoding.BinaryUnmarshaler interface.
+*/
+func (replacer *Replacer) UnmarshalBinary(data []byte) error {
+	values, err := NewMapFromBinary[string](data, StringCodec{})
+	if err != nil {
+		return err
+	}
+	replacer.values = values
+	return nil
+}
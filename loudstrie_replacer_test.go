@@ -0,0 +1,107 @@
+package loudstrie
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReplacer(t *testing.T) {
+	pairs := []string{
+		"&", "&amp;",
+		"<", "&lt;",
+		">", "&gt;",
+		"\"", "&quot;",
+		"'", "&#39;",
+	}
+	for _, useTailTrie := range []bool{true, false} {
+		replacer, err := NewReplacerFromPairs(useTailTrie, pairs...)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		expected := strings.NewReplacer(pairs...)
+		for _, text := range []string{"", "<a href=\"x\">Tom & Jerry's</a>", "plain text", "&&"} {
+			if got := replacer.Replace(text); got != expected.Replace(text) {
+				t.Error("Expected", expected.Replace(text), "got", got)
+			}
+		}
+	}
+
+	pairs2 := []string{"a", "1", "ab", "2", "abc", "3", "", "X", "b", "B"}
+	replacer, _ := NewReplacerFromPairs(false, pairs2...)
+	cases := map[string]string{
+		"abcd":  "3XdX",
+		"abx":   "2XxX",
+		"":      "X",
+		"\xffb": "X\xffBX",
+		"ba":    "B1X",
+	}
+	for text, expected := range cases {
+		if got := replacer.Replace(text); got != expected {
+			t.Errorf("Expected %q got %q for %q", expected, got, text)
+		}
+	}
+
+	var buf bytes.Buffer
+	n, err := replacer.WriteString(&buf, "abcd")
+	if err != nil || n != 4 || buf.String() != "3XdX" {
+		t.Error(n, err, buf.String())
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		replacer.WriteString(io.Discard, "abcd abx ba")
+	})
+	if allocs != 0 {
+		t.Error("WriteString must not allocate", allocs)
+	}
+
+	bin, err := replacer.MarshalBinary()
+	if err != nil {
+		t.Error(err)
+	}
+	newReplacer, err := NewReplacerFromBinary(bin)
+	if err != nil {
+		t.Error(err)
+	}
+	for text, expected := range cases {
+		if got := newReplacer.Replace(text); got != expected {
+			t.Errorf("Expected %q got %q for %q", expected, got, text)
+		}
+	}
+
+	trie, _ := NewTrie([]string{"cat", "dog"}, true)
+	replacements := make([]string, 2)
+	catID, _ := trie.ExactMatchSearch("cat")
+	dogID, _ := trie.ExactMatchSearch("dog")
+	replacements[catID] = "dog"
+	replacements[dogID] = "cat"
+	replacer, err = NewReplacer(trie, replacements)
+	if err != nil {
+		t.Error(err)
+	}
+	if got := replacer.Replace("cat and dog"); got != "dog and cat" {
+		t.Error("Expected dog and cat got", got)
+	}
+
+	// Longer keys inserted into DynamicTrie are matched, and deleted keys aren't.
+	dynamic, _ := NewDynamicTrie(trie)
+	catsID, _ := dynamic.Insert("cats")
+	dynamic.Delete("dog")
+	replacer, err = NewReplacer(dynamic, append(replacements[:2:2], "dogs"))
+	if err != nil {
+		t.Error(err)
+	}
+	if catsID != 2 {
+		t.Error("Expected 2 got", catsID)
+	}
+	if got := replacer.Replace("cats, cat and dog"); got != "dogs, dog and dog" {
+		t.Error("Expected dogs, dog and dog got", got)
+	}
+	if _, err := NewReplacer(trie, replacements[1:]); err != ErrorInvalidReplacements {
+		t.Error(err)
+	}
+	if _, err := NewReplacerFromPairs(false, "a"); err != ErrorInvalidReplacements {
+		t.Error(err)
+	}
+}
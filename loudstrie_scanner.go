@@ -0,0 +1,333 @@
+package loudstrie
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"sort"
+
+	"github.com/hideo55/go-sbvector"
+)
+
+/*
+Match holds result of scanning text.
+*/
+type Match struct {
+	// Start is offset of the first byte of the key in the text.
+	Start uint64
+	// End is offset of the byte that follows the key in the text.
+	End uint64
+	// ID of the key.
+	ID uint64
+}
+
+/*
+Scanner finds all keys of LOUDS Trie that occur in text with Aho-Corasick algorithm.
+
+States of the automaton are the nodes of the trie and the positions in TAILs.
+State ID of a node is its node ID, and state IDs of positions in TAILs follow them.
+*/
+type Scanner struct {
+	trie        *TrieData
+	numOfNodes  uint64
+	numOfStates uint64
+	// tailBase[i] is the first state ID of i-th TAIL minus numOfNodes, and offset of i-th TAIL in tails.
+	tailBase []uint64
+	// tails holds TAILs decoded in advance, so that states in TAILs are entered without decoding.
+	tails []byte
+	// failure[s] is state ID of the longest proper suffix of the state s.
+	failure     sbvector.SuccinctBitVector
+	failureSize uint64
+	// output[s] is state ID + 1 of the longest proper suffix of the state s that is a key, or 0.
+	output     sbvector.SuccinctBitVector
+	outputSize uint64
+	// keyLens[rank] is length of the key that has rank in terminal.
+	keyLens    sbvector.SuccinctBitVector
+	keyLenSize uint64
+}
+
+// scannerState is expanded state of the automaton.
+type scannerState struct {
+	id      uint64
+	nodeID  uint64
+	pos     uint64
+	zeros   uint64
+	hasTail bool
+	tail    []byte
+	// tailPos is number of bytes of the TAIL that are matched.
+	tailPos uint64
+}
+
+var (
+	// ErrorUnsupportedTrie indicates that the Trie implementation isn't supported.
+	ErrorUnsupportedTrie = errors.New("loudstrie: unsupported Trie implementation")
+)
+
+/*
+NewScanner returns new Scanner that finds keys of the trie.
+The trie must be created by NewTrie, NewTrieWithOptions or NewTrieFromBinary.
+*/
+func NewScanner(trie Trie) (*Scanner, error) {
+	trieData, ok := trie.(*TrieData)
+	if !ok {
+		return nil, ErrorUnsupportedTrie
+	}
+	scanner := &Scanner{trie: trieData}
+	scanner.build()
+	return scanner, nil
+}
+
+func (scanner *Scanner) build() {
+	trie := scanner.trie
+	scanner.numOfNodes = trie.terminal.Size()
+	numOfTails := trie.tail.NumOfBits(true)
+	scanner.tailBase = make([]uint64, numOfTails)
+	for i := uint64(0); i < numOfTails; i++ {
+		scanner.tailBase[i] = uint64(len(scanner.tails))
+		scanner.tails = trie.appendTail(scanner.tails, i)
+	}
+	scanner.numOfStates = scanner.numOfNodes + uint64(len(scanner.tails))
+
+	failure := make([]uint64, scanner.numOfStates)
+	output := make([]uint64, scanner.numOfStates)
+	depths := make([]uint64, scanner.numOfStates)
+	keyLens := make([]uint64, trie.terminal.NumOfBits(true))
+	maxKeyLen := uint64(0)
+
+	// Visit states in breadth-first order, so failure of a state is computed before its children.
+	queue := []uint64{}
+	if scanner.numOfNodes != 0 {
+		queue = append(queue, 0)
+	}
+	for len(queue) != 0 {
+		state := scanner.loadState(queue[0])
+		queue = queue[1:]
+		if scanner.isKey(&state) {
+			rank, _ := trie.terminal.Rank1(state.nodeID)
+			keyLens[rank] = depths[state.id]
+			maxKeyLen = max(maxKeyLen, depths[state.id])
+		}
+		scanner.eachChild(&state, func(c byte, child uint64) {
+			depths[child] = depths[state.id] + uint64(1)
+			queue = append(queue, child)
+			if state.id == 0 {
+				return
+			}
+			f := scanner.loadState(failure[state.id])
+			for {
+				next, found := scanner.transition(&f, c)
+				if found {
+					failure[child] = next.id
+					break
+				}
+				if f.id == 0 {
+					break
+				}
+				f = scanner.loadState(failure[f.id])
+			}
+			f = scanner.loadState(failure[child])
+			if f.id != 0 && scanner.isKey(&f) {
+				output[child] = f.id + uint64(1)
+			} else {
+				output[child] = output[f.id]
+			}
+		})
+	}
+
+	scanner.failureSize = max(lg2(scanner.numOfStates), 1)
+	failureBuilder := sbvector.NewVectorBuilder()
+	for _, f := range failure {
+		failureBuilder.PushBackBits(f, scanner.failureSize)
+	}
+	scanner.failure, _ = failureBuilder.Build(false, false)
+
+	scanner.outputSize = max(lg2(scanner.numOfStates+uint64(1)), 1)
+	outputBuilder := sbvector.NewVectorBuilder()
+	for _, o := range output {
+		outputBuilder.PushBackBits(o, scanner.outputSize)
+	}
+	scanner.output, _ = outputBuilder.Build(false, false)
+
+	scanner.keyLenSize = max(lg2(maxKeyLen), 1)
+	keyLensBuilder := sbvector.NewVectorBuilder()
+	for _, l := range keyLens {
+		keyLensBuilder.PushBackBits(l, scanner.keyLenSize)
+	}
+	scanner.keyLens, _ = keyLensBuilder.Build(false, false)
+}
+
+// loadState expands the state ID.
+func (scanner *Scanner) loadState(id uint64) scannerState {
+	trie := scanner.trie
+	state := scannerState{id: id, nodeID: id}
+	if id >= scanner.numOfNodes {
+		offset := id - scanner.numOfNodes
+		tailRank := uint64(sort.Search(len(scanner.tailBase), func(i int) bool {
+			return scanner.tailBase[i] > offset
+		}) - 1)
+		state.nodeID, _ = trie.tail.Select1(tailRank)
+		state.tailPos = offset - scanner.tailBase[tailRank] + uint64(1)
+	}
+	state.pos, state.zeros = trie.getNode(state.nodeID)
+	state.hasTail, _ = trie.tail.Get(state.nodeID)
+	if state.hasTail {
+		tailRank, _ := trie.tail.Rank1(state.nodeID)
+		end := uint64(len(scanner.tails))
+		if tailRank+uint64(1) < uint64(len(scanner.tailBase)) {
+			end = scanner.tailBase[tailRank+uint64(1)]
+		}
+		state.tail = scanner.tails[scanner.tailBase[tailRank]:end]
+	}
+	return state
+}
+
+// tailStateID returns state ID of the position in the TAIL of the node.
+func (scanner *Scanner) tailStateID(nodeID uint64, tailPos uint64) uint64 {
+	if tailPos == 0 {
+		return nodeID
+	}
+	tailRank, _ := scanner.trie.tail.Rank1(nodeID)
+	return scanner.numOfNodes + scanner.tailBase[tailRank] + tailPos - uint64(1)
+}
+
+// isKey returns true if the path from the root to the state is a key.
+func (scanner *Scanner) isKey(state *scannerState) bool {
+	if state.hasTail {
+		return state.tailPos == uint64(len(state.tail))
+	}
+	ok, _ := scanner.trie.terminal.Get(state.nodeID)
+	return ok
+}
+
+func (scanner *Scanner) eachChild(state *scannerState, fn func(c byte, child uint64)) {
+	trie := scanner.trie
+	if state.hasTail {
+		if state.tailPos < uint64(len(state.tail)) {
+			fn(state.tail[state.tailPos], scanner.tailStateID(state.nodeID, state.tailPos+uint64(1)))
+		}
+		return
+	}
+	for i := uint64(0); !trie.isLeaf(state.pos + i); i++ {
+		// Node ID of a child equals to number of zeros before the edge to the child.
+		fn(trie.edges[state.zeros+i-uint64(2)], state.zeros+i-uint64(1))
+	}
+}
+
+// transition returns the state that follows the state by c.
+func (scanner *Scanner) transition(state *scannerState, c byte) (scannerState, bool) {
+	if state.hasTail {
+		if state.tailPos < uint64(len(state.tail)) && state.tail[state.tailPos] == c {
+			next := *state
+			next.tailPos++
+			next.id = scanner.tailStateID(state.nodeID, next.tailPos)
+			return next, true
+		}
+		return scannerState{}, false
+	}
+	pos := state.pos
+	zeros := state.zeros
+	scanner.trie.getChild(c, &pos, &zeros)
+	if pos == NotFound {
+		return scannerState{}, false
+	}
+	return scanner.loadState(pos - zeros), true
+}
+
+func (scanner *Scanner) getFailure(id uint64) uint64 {
+	f, _ := scanner.failure.GetBits(scanner.failureSize*id, scanner.failureSize)
+	return f
+}
+
+func (scanner *Scanner) getOutput(id uint64) uint64 {
+	o, _ := scanner.output.GetBits(scanner.outputSize*id, scanner.outputSize)
+	return o
+}
+
+// step moves the state by c, and calls fn for each key that ends at offset end.
+func (scanner *Scanner) step(state *scannerState, c byte, end uint64, fn func(Match) bool) bool {
+	for {
+		next, found := scanner.transition(state, c)
+		if found {
+			*state = next
+			break
+		}
+		if state.id == 0 {
+			return true
+		}
+		*state = scanner.loadState(scanner.getFailure(state.id))
+	}
+	if scanner.isKey(state) && !scanner.emit(state.nodeID, end, fn) {
+		return false
+	}
+	for o := scanner.getOutput(state.id); o != 0; o = scanner.getOutput(o - uint64(1)) {
+		nodeID := o - uint64(1)
+		if nodeID >= scanner.numOfNodes {
+			nodeID = scanner.loadState(nodeID).nodeID
+		}
+		if !scanner.emit(nodeID, end, fn) {
+			return false
+		}
+	}
+	return true
+}
+
+func (scanner *Scanner) emit(nodeID uint64, end uint64, fn func(Match) bool) bool {
+	id, found := scanner.trie.terminalID(nodeID)
+	if !found {
+		return true
+	}
+	rank, _ := scanner.trie.terminal.Rank1(nodeID)
+	keyLen, _ := scanner.keyLens.GetBits(scanner.keyLenSize*rank, scanner.keyLenSize)
+	return fn(Match{end - keyLen, end, id})
+}
+
+/*
+FindAll finds all keys that occur in text.
+
+This function returns slice of `Match` in ascending order of End, and matches that have same End are in descending order of length.
+Empty key isn't reported.
+*/
+func (scanner *Scanner) FindAll(text string, limit uint64) []Match {
+	var res []Match
+	if limit == 0 {
+		limit = noLimit
+	}
+	if scanner.numOfNodes == 0 {
+		return res
+	}
+	state := scanner.loadState(0)
+	fn := func(m Match) bool {
+		res = append(res, m)
+		return uint64(len(res)) < limit
+	}
+	for i := 0; i < len(text); i++ {
+		if !scanner.step(&state, text[i], uint64(i+1), fn) {
+			break
+		}
+	}
+	return res
+}
+
+/*
+ScanReader finds all keys that occur in text read from r, and calls fn for each match in same order as FindAll.
+If fn returns false, scanning stops.
+*/
+func (scanner *Scanner) ScanReader(r io.Reader, fn func(Match) bool) error {
+	if scanner.numOfNodes == 0 {
+		return nil
+	}
+	reader := bufio.NewReader(r)
+	state := scanner.loadState(0)
+	for offset := uint64(1); ; offset++ {
+		c, err := reader.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !scanner.step(&state, c, offset, fn) {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package loudstrie
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func findAllNaive(keyList []string, text string) []Match {
+	var res []Match
+	for end := 1; end <= len(text); end++ {
+		for _, key := range keyList {
+			if key != "" && strings.HasSuffix(text[:end], key) {
+				res = append(res, Match{uint64(end - len(key)), uint64(end), 0})
+			}
+		}
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		if res[i].End != res[j].End {
+			return res[i].End < res[j].End
+		}
+		return res[i].Start < res[j].Start
+	})
+	return res
+}
+
+func TestScanner(t *testing.T) {
+	keyList := []string{
+		"he",
+		"she",
+		"his",
+		"hers",
+		"usher",
+		"ushers",
+		"s",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		scanner, err := NewScanner(*trie)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		text := "ushers and his sheep"
+		expected := findAllNaive(keyList, text)
+		matches := scanner.FindAll(text, 0)
+		if len(matches) != len(expected) {
+			t.Error("Expected", expected, "got", matches)
+			continue
+		}
+		for i, m := range matches {
+			key, _ := (*trie).DecodeKey(m.ID)
+			if m.Start != expected[i].Start || m.End != expected[i].End || text[m.Start:m.End] != key {
+				t.Error("Expected", expected[i], "got", m, key)
+			}
+		}
+
+		if matches = scanner.FindAll(text, 2); len(matches) != 2 {
+			t.Error(matches)
+		}
+
+		var readerMatches []Match
+		err = scanner.ScanReader(strings.NewReader(text), func(m Match) bool {
+			readerMatches = append(readerMatches, m)
+			return true
+		})
+		if err != nil || len(readerMatches) != len(expected) {
+			t.Error("Expected", expected, "got", readerMatches, err)
+		}
+		for i, m := range readerMatches {
+			if m != scanner.FindAll(text, 0)[i] {
+				t.Error("Expected", scanner.FindAll(text, 0)[i], "got", m)
+			}
+		}
+	}
+
+	keyList2 := genKeyList(500, 6)
+	for i := range keyList2 {
+		keyList2[i] = strings.ToLower(keyList2[i])
+	}
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	text := strings.ToLower(randStr(3000))
+	keyList2 = removeDuplicates(keyList2)
+	expected := findAllNaive(keyList2, text)
+	for _, trie := range tries2 {
+		scanner, _ := NewScanner(*trie)
+		matches := scanner.FindAll(text, 0)
+		if len(matches) != len(expected) {
+			t.Error("Expected", len(expected), "got", len(matches))
+			continue
+		}
+		for i, m := range matches {
+			key, _ := (*trie).DecodeKey(m.ID)
+			if m.Start != expected[i].Start || m.End != expected[i].End || text[m.Start:m.End] != key {
+				t.Error("Expected", expected[i], "got", m, key)
+			}
+		}
+	}
+
+	trie5, _ := NewTrie([]string{}, false)
+	scanner, _ := NewScanner(trie5)
+	if matches := scanner.FindAll("abc", 0); len(matches) != 0 {
+		t.Error(matches)
+	}
+}
+
+func TestScannerAllocs(t *testing.T) {
+	keyList := []string{"he", "she", "his", "hers", "usher", "ushers", "history", "shepherd"}
+	text := strings.Repeat("ushers saw his history of shepherds. ", 10)
+	for _, useTailTrie := range []bool{true, false} {
+		trie, _ := NewTrie(keyList, useTailTrie)
+		scanner, _ := NewScanner(trie)
+		numOfMatches := 0
+		fn := func(m Match) bool {
+			numOfMatches++
+			return true
+		}
+		allocs := testing.AllocsPerRun(10, func() {
+			state := scanner.loadState(0)
+			for i := 0; i < len(text); i++ {
+				scanner.step(&state, text[i], uint64(i+1), fn)
+			}
+		})
+		if allocs != 0 {
+			t.Error("Scanning must not allocate", useTailTrie, allocs)
+		}
+		if numOfMatches == 0 {
+			t.Error("No match is found")
+		}
+	}
+}
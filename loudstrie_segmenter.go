@@ -0,0 +1,158 @@
+package loudstrie
+
+import (
+	"errors"
+	"math"
+	"unicode/utf8"
+)
+
+/*
+SegmentMode specifies how Segmenter selects tokens.
+*/
+type SegmentMode int
+
+const (
+	// LongestMatch selects the longest key at each position from the beginning of the text.
+	LongestMatch SegmentMode = iota
+	// MinimumCost selects the sequence of tokens that minimizes total cost.
+	MinimumCost
+)
+
+/*
+Token holds a span of the segmented text.
+*/
+type Token struct {
+	// Start is offset of the first byte of the token in the text.
+	Start uint64
+	// End is offset of the byte that follows the token in the text.
+	End uint64
+	// ID of the key. If the token is unknown word, ID is `loudstrie.NotFound`.
+	ID uint64
+}
+
+/*
+Segmenter splits text into keys of LOUDS Trie.
+
+Characters that aren't covered by any key are regarded as unknown word.
+Consecutive unknown characters are merged into one token.
+*/
+type Segmenter struct {
+	trie        Trie
+	mode        SegmentMode
+	costs       []int64
+	unknownCost int64
+}
+
+var (
+	// ErrorInvalidCosts indicates that number of costs does not match number of keys.
+	ErrorInvalidCosts = errors.New("NewSegmenter: number of costs does not match number of keys")
+)
+
+/*
+NewSegmenter returns new Segmenter.
+
+costs[id] is cost of the key of the ID, and it is used in MinimumCost mode. If costs is nil, cost of every key is 1.
+unknownCost is cost of an unknown character.
+*/
+func NewSegmenter(trie Trie, mode SegmentMode, costs []int64, unknownCost int64) (*Segmenter, error) {
+	if costs != nil && uint64(len(costs)) < numOfIDs(trie) {
+		return nil, ErrorInvalidCosts
+	}
+	segmenter := &Segmenter{
+		trie:        trie,
+		mode:        mode,
+		costs:       costs,
+		unknownCost: unknownCost,
+	}
+	return segmenter, nil
+}
+
+/*
+Segment splits text into tokens.
+*/
+func (segmenter *Segmenter) Segment(text string) []Token {
+	if segmenter.mode == MinimumCost {
+		return segmenter.segmentMinimumCost(text)
+	}
+	return segmenter.segmentLongestMatch(text)
+}
+
+func (segmenter *Segmenter) segmentLongestMatch(text string) []Token {
+	var res []Token
+	textLen := uint64(len(text))
+	for i := uint64(0); i < textLen; {
+		results := segmenter.trie.CommonPrefixSearch(text[i:], 0)
+		if len(results) != 0 && results[len(results)-1].Length != 0 {
+			longest := results[len(results)-1]
+			res = append(res, Token{i, i + longest.Length, longest.ID})
+			i += longest.Length
+			continue
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		res = appendUnknown(res, i, i+uint64(size))
+		i += uint64(size)
+	}
+	return res
+}
+
+func (segmenter *Segmenter) segmentMinimumCost(text string) []Token {
+	textLen := uint64(len(text))
+	// best[i] is minimum cost of text[:i], and prev[i] is the last token of it.
+	best := make([]int64, textLen+1)
+	prev := make([]Token, textLen+1)
+	for i := range best {
+		best[i] = math.MaxInt64
+	}
+	best[0] = 0
+	for i := uint64(0); i < textLen; i++ {
+		if best[i] == math.MaxInt64 {
+			continue
+		}
+		for _, item := range segmenter.trie.CommonPrefixSearch(text[i:], 0) {
+			if item.Length == 0 {
+				continue
+			}
+			end := i + item.Length
+			if cost := best[i] + segmenter.cost(item.ID); cost < best[end] {
+				best[end] = cost
+				prev[end] = Token{i, end, item.ID}
+			}
+		}
+		_, size := utf8.DecodeRuneInString(text[i:])
+		end := i + uint64(size)
+		if cost := best[i] + segmenter.unknownCost; cost < best[end] {
+			best[end] = cost
+			prev[end] = Token{i, end, NotFound}
+		}
+	}
+
+	var reversed []Token
+	for i := textLen; i > 0; i = prev[i].Start {
+		reversed = append(reversed, prev[i])
+	}
+	var res []Token
+	for i := len(reversed) - 1; i >= 0; i-- {
+		if reversed[i].ID == NotFound {
+			res = appendUnknown(res, reversed[i].Start, reversed[i].End)
+		} else {
+			res = append(res, reversed[i])
+		}
+	}
+	return res
+}
+
+func (segmenter *Segmenter) cost(id uint64) int64 {
+	if segmenter.costs == nil {
+		return 1
+	}
+	return segmenter.costs[id]
+}
+
+// appendUnknown appends unknown token, and merges it with the last token if the last token is also unknown.
+func appendUnknown(tokens []Token, start uint64, end uint64) []Token {
+	if len(tokens) != 0 && tokens[len(tokens)-1].ID == NotFound && tokens[len(tokens)-1].End == start {
+		tokens[len(tokens)-1].End = end
+		return tokens
+	}
+	return append(tokens, Token{start, end, NotFound})
+}
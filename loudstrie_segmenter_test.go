@@ -0,0 +1,86 @@
+package loudstrie
+
+import (
+	"strings"
+	"testing"
+)
+
+func tokensToStrings(text string, tokens []Token) []string {
+	var res []string
+	for _, token := range tokens {
+		str := text[token.Start:token.End]
+		if token.ID == NotFound {
+			str = "?" + str
+		}
+		res = append(res, str)
+	}
+	return res
+}
+
+func TestSegmenter(t *testing.T) {
+	keyList := []string{
+		"東京",
+		"東京都",
+		"京都",
+		"都",
+		"に",
+		"住む",
+		"a",
+		"ab",
+		"abc",
+		"cd",
+	}
+	trie, _ := NewTrie(keyList, false)
+
+	longest, _ := NewSegmenter(trie, LongestMatch, nil, 10)
+	cases := map[string]string{
+		"東京都に住む": "東京都,に,住む",
+		"京都に住む":  "京都,に,住む",
+		"東京都xyに": "東京都,?xy,に",
+		"abcd":   "abc,?d",
+		"zz東京":   "?zz,東京",
+		"":       "",
+	}
+	for text, expected := range cases {
+		got := strings.Join(tokensToStrings(text, longest.Segment(text)), ",")
+		if got != expected {
+			t.Error("Expected", expected, "got", got, text)
+		}
+	}
+
+	minimum, _ := NewSegmenter(trie, MinimumCost, nil, 10)
+	cases = map[string]string{
+		"東京都に住む": "東京都,に,住む",
+		"abcd":   "ab,cd",
+		"abxcd":  "ab,?x,cd",
+		"":       "",
+	}
+	for text, expected := range cases {
+		got := strings.Join(tokensToStrings(text, minimum.Segment(text)), ",")
+		if got != expected {
+			t.Error("Expected", expected, "got", got, text)
+		}
+	}
+
+	costs := make([]int64, trie.GetNumOfKeys())
+	for i := range costs {
+		costs[i] = 5
+	}
+	id, _ := trie.ExactMatchSearch("東京都")
+	costs[id] = 20
+	weighted, _ := NewSegmenter(trie, MinimumCost, costs, 100)
+	got := strings.Join(tokensToStrings("東京都", weighted.Segment("東京都")), ",")
+	if got != "東京,都" {
+		t.Error("Expected 東京,都 got", got)
+	}
+	for _, token := range weighted.Segment("東京都") {
+		key, _ := trie.DecodeKey(token.ID)
+		if key != "東京都"[token.Start:token.End] {
+			t.Error("Expected", "東京都"[token.Start:token.End], "got", key)
+		}
+	}
+
+	if _, err := NewSegmenter(trie, MinimumCost, costs[1:], 100); err != ErrorInvalidCosts {
+		t.Error(err)
+	}
+}
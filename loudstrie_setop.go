@@ -0,0 +1,255 @@
+package loudstrie
+
+import (
+	"iter"
+)
+
+// setOp holds which keys are emitted by a set operation.
+type setOp struct {
+	onlyA bool
+	onlyB bool
+	both  bool
+}
+
+var (
+	setUnion      = setOp{onlyA: true, onlyB: true, both: true}
+	setIntersect  = setOp{both: true}
+	setDifference = setOp{onlyA: true}
+)
+
+/*
+setNode is a node of the trie, or a position in a TAIL.
+If hasTail is true, tail holds the rest of the TAIL that isn't consumed yet.
+*/
+type setNode struct {
+	trie    *TrieData
+	pos     uint64
+	zeros   uint64
+	hasTail bool
+	tail    string
+}
+
+// setChild is a child of setNode with label of the edge.
+type setChild struct {
+	c    byte
+	node *setNode
+}
+
+/*
+UnionKeys returns an iterator over keys that are in a or b in lexicographic order.
+*/
+func UnionKeys(a Trie, b Trie) iter.Seq[string] {
+	return setKeys(a, b, setUnion)
+}
+
+/*
+IntersectKeys returns an iterator over keys that are in both a and b in lexicographic order.
+*/
+func IntersectKeys(a Trie, b Trie) iter.Seq[string] {
+	return setKeys(a, b, setIntersect)
+}
+
+/*
+DifferenceKeys returns an iterator over keys that are in a but not in b in lexicographic order.
+*/
+func DifferenceKeys(a Trie, b Trie) iter.Seq[string] {
+	return setKeys(a, b, setDifference)
+}
+
+/*
+Union returns new trie that holds keys in a or b.
+If a is *TrieData, the new trie is built with its UseTailTrie and LexicographicID options.
+*/
+func Union(a Trie, b Trie) (Trie, error) {
+	return newTrieFromSeq(UnionKeys(a, b), a)
+}
+
+/*
+Intersect returns new trie that holds keys in both a and b.
+If a is *TrieData, the new trie is built with its UseTailTrie and LexicographicID options.
+*/
+func Intersect(a Trie, b Trie) (Trie, error) {
+	return newTrieFromSeq(IntersectKeys(a, b), a)
+}
+
+/*
+Difference returns new trie that holds keys in a but not in b.
+If a is *TrieData, the new trie is built with its UseTailTrie and LexicographicID options.
+*/
+func Difference(a Trie, b Trie) (Trie, error) {
+	return newTrieFromSeq(DifferenceKeys(a, b), a)
+}
+
+func newTrieFromSeq(keys iter.Seq[string], template Trie) (Trie, error) {
+	options := TrieOptions{}
+	if trieData, ok := template.(*TrieData); ok {
+		options.UseTailTrie = trieData.hasTailTrie
+		options.LexicographicID = trieData.hasLexID
+	}
+	// keys are enumerated in lexicographic order without duplicates.
+	keyList := []string{}
+	for key := range keys {
+		keyList = append(keyList, key)
+	}
+	return newTrieFromSortedKeys(keyList, options)
+}
+
+func setKeys(a Trie, b Trie, op setOp) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		walkSetIDs(a, b, op, func(key string, idA uint64, idB uint64) bool {
+			return yield(key)
+		})
+	}
+}
+
+/*
+walkSetIDs enumerates keys of the set operation in lexicographic order.
+yield receives the key and IDs of the key in a and b. If the key isn't in a trie, the ID is `loudstrie.NotFound`.
+*/
+func walkSetIDs(a Trie, b Trie, op setOp, yield func(string, uint64, uint64) bool) {
+	trieA, okA := a.(*TrieData)
+	trieB, okB := b.(*TrieData)
+	if !okA || !okB {
+		mergeSetIDs(a, b, op, yield)
+		return
+	}
+	// Walk both tries in lockstep from the roots.
+	walkSet(newSetNode(trieA, uint64(2), uint64(2)), newSetNode(trieB, uint64(2), uint64(2)), nil, op, yield)
+}
+
+// mergeSetIDs computes the set operation by merging keys enumerated from a and b.
+func mergeSetIDs(a Trie, b Trie, op setOp, yield func(string, uint64, uint64) bool) {
+	nextA, stopA := iter.Pull2(allKeys(a))
+	defer stopA()
+	nextB, stopB := iter.Pull2(allKeys(b))
+	defer stopB()
+	idA, keyA, okA := nextA()
+	idB, keyB, okB := nextB()
+	for okA || okB {
+		switch {
+		case !okB || (okA && keyA < keyB):
+			if op.onlyA && !yield(keyA, idA, NotFound) {
+				return
+			}
+			idA, keyA, okA = nextA()
+		case !okA || keyB < keyA:
+			if op.onlyB && !yield(keyB, NotFound, idB) {
+				return
+			}
+			idB, keyB, okB = nextB()
+		default:
+			if op.both && !yield(keyA, idA, idB) {
+				return
+			}
+			idA, keyA, okA = nextA()
+			idB, keyB, okB = nextB()
+		}
+	}
+}
+
+func newSetNode(trie *TrieData, pos uint64, zeros uint64) *setNode {
+	if trie.numOfKeys == 0 {
+		return nil
+	}
+	node := &setNode{trie: trie, pos: pos, zeros: zeros}
+	ones := pos - zeros
+	if ok, _ := trie.tail.Get(ones); ok {
+		tailID, _ := trie.tail.Rank1(ones)
+		node.hasTail = true
+		node.tail = trie.getTail(tailID)
+	}
+	return node
+}
+
+func (node *setNode) terminalID() (uint64, bool) {
+	if node.hasTail && len(node.tail) != 0 {
+		return NotFound, false
+	}
+	return node.trie.terminalID(node.pos - node.zeros)
+}
+
+// children returns children of the node in ascending order of label.
+func (node *setNode) children() []setChild {
+	if node.hasTail {
+		if len(node.tail) == 0 {
+			return nil
+		}
+		child := *node
+		child.tail = node.tail[1:]
+		return []setChild{{node.tail[0], &child}}
+	}
+	var res []setChild
+	trie := node.trie
+	for i := uint64(0); !trie.isLeaf(node.pos + i); i++ {
+		nextPos, _ := trie.louds.Select1(node.zeros + i - uint64(1))
+		nextPos++
+		child := newSetNode(trie, nextPos, nextPos-node.zeros-i+uint64(1))
+		res = append(res, setChild{trie.edges[node.zeros+i-uint64(2)], child})
+	}
+	return res
+}
+
+// walkKeys enumerates keys and IDs in the subtree of the node.
+func (node *setNode) walkKeys(path []byte, yield func(string, uint64) bool) bool {
+	if node.hasTail {
+		if id, found := node.trie.terminalID(node.pos - node.zeros); found {
+			return yield(string(path)+node.tail, id)
+		}
+		return true
+	}
+	return node.trie.walkKeys(node.pos, node.zeros, path, func(id uint64, key []byte) bool {
+		return yield(string(key), id)
+	})
+}
+
+// walkSet walks subtrees of a and b in lockstep, and returns false if yield returns false.
+func walkSet(a *setNode, b *setNode, path []byte, op setOp, yield func(string, uint64, uint64) bool) bool {
+	if a == nil || b == nil {
+		if a != nil && op.onlyA {
+			return a.walkKeys(path, func(key string, id uint64) bool {
+				return yield(key, id, NotFound)
+			})
+		}
+		if b != nil && op.onlyB {
+			return b.walkKeys(path, func(key string, id uint64) bool {
+				return yield(key, NotFound, id)
+			})
+		}
+		return true
+	}
+	idA, terminalA := a.terminalID()
+	idB, terminalB := b.terminalID()
+	if (terminalA && terminalB && op.both) || (terminalA && !terminalB && op.onlyA) || (!terminalA && terminalB && op.onlyB) {
+		if !yield(string(path), idA, idB) {
+			return false
+		}
+	}
+	childrenA := a.children()
+	childrenB := b.children()
+	i := 0
+	j := 0
+	for i < len(childrenA) || j < len(childrenB) {
+		var childA, childB *setNode
+		var c byte
+		switch {
+		case j == len(childrenB) || (i < len(childrenA) && childrenA[i].c < childrenB[j].c):
+			c = childrenA[i].c
+			childA = childrenA[i].node
+			i++
+		case i == len(childrenA) || childrenB[j].c < childrenA[i].c:
+			c = childrenB[j].c
+			childB = childrenB[j].node
+			j++
+		default:
+			c = childrenA[i].c
+			childA = childrenA[i].node
+			childB = childrenB[j].node
+			i++
+			j++
+		}
+		if !walkSet(childA, childB, append(path, c), op, yield) {
+			return false
+		}
+	}
+	return true
+}
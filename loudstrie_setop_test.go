@@ -0,0 +1,97 @@
+package loudstrie
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestSetOperations(t *testing.T) {
+	keyList := genKeyList(2000, 8)
+	keyList = append(keyList, "", "a", "ab", "abc", "abcdefgh")
+	setA := make(map[string]bool)
+	setB := make(map[string]bool)
+	var keysA, keysB []string
+	for i, key := range keyList {
+		if i%3 != 0 {
+			setA[key] = true
+			keysA = append(keysA, key)
+		}
+		if i%2 == 0 || len(key) <= 3 {
+			setB[key] = true
+			keysB = append(keysB, key)
+		}
+	}
+	expectedKeys := func(fn func(string) bool) []string {
+		var res []string
+		seen := make(map[string]bool)
+		for _, key := range keyList {
+			if !seen[key] && fn(key) {
+				res = append(res, key)
+			}
+			seen[key] = true
+		}
+		sort.Strings(res)
+		return res
+	}
+	expectedUnion := expectedKeys(func(key string) bool { return setA[key] || setB[key] })
+	expectedIntersect := expectedKeys(func(key string) bool { return setA[key] && setB[key] })
+	expectedDifference := expectedKeys(func(key string) bool { return setA[key] && !setB[key] })
+
+	for _, useTailTrieA := range []bool{true, false} {
+		for _, useTailTrieB := range []bool{true, false} {
+			a, _ := NewTrie(append([]string(nil), keysA...), useTailTrieA)
+			b, _ := NewTrie(append([]string(nil), keysB...), useTailTrieB)
+			dynamicB, _ := NewDynamicTrie(b)
+			for _, other := range []Trie{b, dynamicB} {
+				collect := func(seq func(func(string) bool)) []string {
+					var res []string
+					for key := range seq {
+						res = append(res, key)
+					}
+					return res
+				}
+				if res := collect(UnionKeys(a, other)); !reflect.DeepEqual(res, expectedUnion) {
+					t.Error("UnionKeys error", useTailTrieA, useTailTrieB, len(res), len(expectedUnion))
+				}
+				if res := collect(IntersectKeys(a, other)); !reflect.DeepEqual(res, expectedIntersect) {
+					t.Error("IntersectKeys error", useTailTrieA, useTailTrieB, len(res), len(expectedIntersect))
+				}
+				if res := collect(DifferenceKeys(a, other)); !reflect.DeepEqual(res, expectedDifference) {
+					t.Error("DifferenceKeys error", useTailTrieA, useTailTrieB, len(res), len(expectedDifference))
+				}
+			}
+
+			union, err := Union(a, b)
+			if err != nil || union.GetNumOfKeys() != uint64(len(expectedUnion)) {
+				t.Error("Union error", err)
+			}
+			intersect, err := Intersect(a, b)
+			if err != nil || intersect.GetNumOfKeys() != uint64(len(expectedIntersect)) {
+				t.Error("Intersect error", err)
+			}
+			difference, err := Difference(a, b)
+			if err != nil || difference.GetNumOfKeys() != uint64(len(expectedDifference)) {
+				t.Error("Difference error", err)
+			}
+			for _, key := range expectedDifference {
+				if _, found := difference.ExactMatchSearch(key); !found {
+					t.Error("Key is not found", key)
+				}
+			}
+		}
+	}
+
+	empty, _ := NewTrie([]string{}, false)
+	a, _ := NewTrie(keysA, true)
+	count := 0
+	for range UnionKeys(empty, a) {
+		count++
+	}
+	if uint64(count) != a.GetNumOfKeys() {
+		t.Error("Expected", a.GetNumOfKeys(), "got", count)
+	}
+	for range IntersectKeys(a, empty) {
+		t.Error("Intersection with empty trie must be empty")
+	}
+}
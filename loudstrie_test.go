@@ -3,7 +3,10 @@ package loudstrie
 import (
 	"crypto/rand"
 	mrand "math/rand"
+	"slices"
 	"testing"
+
+	"github.com/hideo55/go-sbvector"
 )
 
 func TestBuild(t *testing.T) {
@@ -197,6 +200,73 @@ func TestPredictiveSearch(t *testing.T) {
 		if len(results) != 0 {
 			t.Error(results)
 		}
+		results = (*trie).PredictiveSearch("bbcd", 0)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+	}
+}
+
+func TestPredictiveSearchKeys(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	tries := []*Trie{&trie1, &trie2}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		results := trieData.PredictiveSearchKeys("ab", 0)
+		if len(results) != 3 {
+			t.Error(results)
+		}
+		for _, item := range results {
+			key, _ := (*trie).DecodeKey(item.ID)
+			if key != item.Key {
+				t.Error("Expected", key, "got", item.Key)
+			}
+		}
+		if results[0].Key != "abc" || results[1].Key != "abcde" || results[2].Key != "able" {
+			t.Error(results)
+		}
+		results = trieData.PredictiveSearchKeys("ab", 2)
+		if len(results) != 2 {
+			t.Error(results)
+		}
+		results = trieData.PredictiveSearchKeys("ca", 0)
+		if len(results) != 1 || results[0].Key != "can" {
+			t.Error(results)
+		}
+		results = trieData.PredictiveSearchKeys("cas", 0)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+	}
+
+	keyList2 := genKeyList(3000, 30)
+	trie3, _ := NewTrie(keyList2, true)
+	trie4, _ := NewTrie(keyList2, false)
+	tries2 := []*Trie{&trie3, &trie4}
+	for _, trie := range tries2 {
+		trieData := (*trie).(*TrieData)
+		for _, prefix := range []string{"", "a", "Z9"} {
+			ids := (*trie).PredictiveSearch(prefix, 0)
+			results := trieData.PredictiveSearchKeys(prefix, 0)
+			if len(ids) != len(results) {
+				t.Error("Expected", len(ids), "got", len(results))
+			}
+			for _, item := range results {
+				key, _ := (*trie).DecodeKey(item.ID)
+				if key != item.Key {
+					t.Error("Expected", key, "got", item.Key)
+				}
+			}
+		}
 	}
 }
 
@@ -253,14 +323,18 @@ func TestMarshalBinary(t *testing.T) {
 
 	var buf []byte
 	_, err := NewTrieFromBinary(buf)
-	if err == nil || err != ErrorInvalidFormat {
+	if err == nil || err != ErrorNotLoudsTrie {
 		t.Error()
 	}
 
 	for i := 1; i < len(triebin)-1; i++ {
 		buf = triebin[0:i]
 		_, err = NewTrieFromBinary(buf)
-		if err == nil || err != ErrorInvalidFormat {
+		expected := ErrorInvalidFormat
+		if i < len(formatMagic) {
+			expected = ErrorNotLoudsTrie
+		}
+		if err == nil || err != expected {
 			t.Error()
 		}
 	}
@@ -280,6 +354,165 @@ func TestTraverse(t *testing.T) {
 	}
 }
 
+func TestBinaryKeys(t *testing.T) {
+	keyList := make([]string, 3000)
+	for i := range keyList {
+		buf := make([]byte, mrand.Intn(20)+1)
+		rand.Read(buf)
+		keyList[i] = string(buf)
+	}
+	keyList = append(keyList, "\xe6\x97\xa5\xe6\x9c", "\xe6\x97\xa5\xe6\x9c\xac\xe8\xaa\x9e", "a\x00\xff\xfe", "a\x00")
+	trie1, _ := NewTrie(keyList, false)
+	trie2, _ := NewTrie(keyList, true)
+
+	bin, _ := trie2.MarshalBinary()
+	newtrie, err := NewTrieFromBinary(bin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Tails that have non-ASCII bytes are written without the tail trie in the legacy format.
+	legacy, _ := trie2.(*TrieData).MarshalBinaryLegacy()
+	legacyTrie, err := NewTrieFromBinary(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, trie := range []Trie{trie2, newtrie, legacyTrie} {
+		for _, key := range keyList {
+			id1, found1 := trie1.ExactMatchSearch(key)
+			id2, found2 := trie.ExactMatchSearch(key)
+			if !found1 || !found2 || id1 != id2 {
+				t.Errorf("Expected %d got %d %q", id1, id2, key)
+			}
+			decode, _ := trie.DecodeKey(id2)
+			if decode != key {
+				t.Errorf("Expected %q got %q", key, decode)
+			}
+		}
+	}
+}
+
+func TestRuneReversedTails(t *testing.T) {
+	// Older versions reversed tails in the tail trie by runes.
+	// Tails are valid UTF-8, because tails that aren't valid UTF-8 were broken by older versions.
+	keyList := []string{"a日本語", "b日本酒です", "cabcdef", "xyz"}
+	trie, _ := NewTrie(keyList, true)
+	trieData := trie.(*TrieData)
+	numOfTails := trieData.tail.NumOfBits(true)
+	tails := make([]string, numOfTails)
+	for i := range tails {
+		tails[i] = reverseRunes(trieData.getTail(uint64(i)))
+	}
+	// NewTrie sorts the key list, so a copy of tails is passed.
+	tailTrie, _ := NewTrie(append([]string{}, tails...), false)
+	tailIDBuilder := sbvector.NewVectorBuilder()
+	for _, tail := range tails {
+		id, _ := tailTrie.ExactMatchSearch(tail)
+		tailIDBuilder.PushBackBits(id, trieData.tailIDSize)
+	}
+	old := *trieData
+	old.tailTrie = tailTrie
+	old.tailIDs, _ = tailIDBuilder.Build(false, false)
+	old.runeReverse = true
+
+	legacy, _ := old.MarshalBinaryLegacy()
+	bin, _ := old.MarshalBinary()
+	for _, data := range [][]byte{legacy, bin} {
+		newtrie, err := NewTrieFromBinary(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, key := range keyList {
+			id, found := newtrie.ExactMatchSearch(key)
+			if !found {
+				t.Error("Not found", key)
+			}
+			if decode, _ := newtrie.DecodeKey(id); decode != key {
+				t.Error("Expected", key, "got", decode)
+			}
+		}
+	}
+}
+
+func TestBytesKeys(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	byteKeyList := make([][]byte, len(keyList))
+	for i, key := range keyList {
+		byteKeyList[i] = []byte(key)
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	byteTrie, _ := NewTrieFromByteKeys(byteKeyList, TrieOptions{UseTailTrie: true, LexicographicID: true})
+	trie4, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, LexicographicID: true})
+	trie3 := byteTrie.(*TrieData)
+	if trie3.GetNumOfKeys() != trie4.GetNumOfKeys() {
+		t.Error("Expected", trie4.GetNumOfKeys(), "got", trie3.GetNumOfKeys())
+	}
+	for _, key := range byteKeyList {
+		id1, _ := trie3.ExactMatchSearchBytes(key)
+		id2, _ := trie4.ExactMatchSearch(string(key))
+		if id1 != id2 {
+			t.Error("Expected", id2, "got", id1)
+		}
+	}
+
+	queries := append(slices.Clone(byteKeyList), []byte(""), []byte("a"), []byte("zzzzzzzzzz"))
+	for _, trie := range []*TrieData{trie1.(*TrieData), trie2.(*TrieData), trie3} {
+		var buf []byte
+		for _, query := range queries {
+			id1, found1 := trie.ExactMatchSearch(string(query))
+			id2, found2 := trie.ExactMatchSearchBytes(query)
+			if id1 != id2 || found1 != found2 {
+				t.Error("Expected", id1, "got", id2)
+			}
+			if res1, res2 := trie.CommonPrefixSearch(string(query), 0), trie.CommonPrefixSearchBytes(query, 0); !slices.Equal(res1, res2) {
+				t.Error("Expected", res1, "got", res2)
+			}
+			if len(query) > 2 {
+				query = query[:2]
+			}
+			if res1, res2 := trie.PredictiveSearch(string(query), 0), trie.PredictiveSearchBytes(query, 0); !slices.Equal(res1, res2) {
+				t.Error("Expected", res1, "got", res2)
+			}
+			if !found1 {
+				continue
+			}
+			key, _ := trie.DecodeKey(id1)
+			buf, found2 = trie.AppendKey(buf[:0], id1)
+			if !found2 || string(buf) != key {
+				t.Errorf("Expected %q got %q", key, buf)
+			}
+		}
+		prefixed, found := trie.AppendKey([]byte("prefix:"), 0)
+		key, _ := trie.DecodeKey(0)
+		if !found || string(prefixed) != "prefix:"+key {
+			t.Errorf("AppendKey must append to dst %q", prefixed)
+		}
+		if res, found := trie.AppendKey(buf[:0], trie.GetNumOfKeys()); found || len(res) != 0 {
+			t.Error("AppendKey error for ID that does not exist in the trie.")
+		}
+	}
+}
+
+func TestBytesKeysAllocs(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	for _, useTailTrie := range []bool{true, false} {
+		newTrie, _ := NewTrie(keyList, useTailTrie)
+		trie := newTrie.(*TrieData)
+		query := []byte(keyList[0])
+		id, _ := trie.ExactMatchSearchBytes(query)
+		buf := make([]byte, 0, 64)
+		allocs := testing.AllocsPerRun(100, func() {
+			if _, found := trie.ExactMatchSearchBytes(query); !found {
+				t.Error("Not found", keyList[0])
+			}
+			buf, _ = trie.AppendKey(buf[:0], id)
+		})
+		if allocs != 0 {
+			t.Error("ExactMatchSearchBytes and AppendKey must not allocate", useTailTrie, allocs)
+		}
+	}
+}
+
 func randStr(strSize uint) string {
 
 	var dictionary string
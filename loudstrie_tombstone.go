@@ -0,0 +1,147 @@
+package loudstrie
+
+import (
+	"math/bits"
+	"slices"
+
+	"github.com/hideo55/go-sbvector"
+)
+
+/*
+Delete marks the key as deleted.
+
+IDs of other keys aren't changed, and the ID of the deleted key isn't reused.
+Deleted keys aren't found by searches, and DecodeKey returns false for them.
+If the key doesn't exist, this function returns false.
+Delete must not be called concurrently with other methods.
+*/
+func (trie *TrieData) Delete(key string) bool {
+	id, found := trie.ExactMatchSearch(key)
+	if !found {
+		return false
+	}
+	return trie.DeleteID(id)
+}
+
+/*
+DeleteID marks the key of the ID as deleted.
+If the ID doesn't exist, this function returns false.
+*/
+func (trie *TrieData) DeleteID(id uint64) bool {
+	numOfTerminals := trie.terminal.NumOfBits(true)
+	if numOfTerminals <= id {
+		return false
+	}
+	rank := trie.rankOfID(id)
+	if trie.isDeleted(rank) {
+		return false
+	}
+	if trie.tombstones == nil {
+		trie.tombstones = make([]uint64, (numOfTerminals+uint64(63))/uint64(64))
+	}
+	trie.tombstones[rank/uint64(64)] |= uint64(1) << (rank % uint64(64))
+	trie.numOfDeleted++
+	if trie.hasLexID {
+		// ID is lexicographic ID.
+		i, _ := slices.BinarySearch(trie.deletedLexIDs, id)
+		trie.deletedLexIDs = slices.Insert(trie.deletedLexIDs, i, id)
+	}
+	return true
+}
+
+// rankOfID returns rank in terminal of the key of the ID.
+func (trie *TrieData) rankOfID(id uint64) uint64 {
+	if trie.hasLexID {
+		rank, _ := trie.lexRanks.GetBits(trie.lexIDSize*id, trie.lexIDSize)
+		return rank
+	}
+	return id
+}
+
+// isDeleted returns true if the key that has rank in terminal is deleted.
+func (trie *TrieData) isDeleted(rank uint64) bool {
+	if trie.numOfDeleted == 0 {
+		return false
+	}
+	return trie.tombstones[rank/uint64(64)]&(uint64(1)<<(rank%uint64(64))) != 0
+}
+
+func (trie *TrieData) marshalTombstones() ([]byte, error) {
+	builder := sbvector.NewVectorBuilder()
+	numOfTerminals := trie.terminal.NumOfBits(true)
+	for rank := uint64(0); rank < numOfTerminals; rank++ {
+		builder.PushBack(trie.isDeleted(rank))
+	}
+	tombstones, err := builder.Build(false, false)
+	if err != nil {
+		return nil, err
+	}
+	return tombstones.MarshalBinary()
+}
+
+func (trie *TrieData) unmarshalTombstones(data []byte) error {
+	tombstones, err := sbvector.NewVectorFromBinary(data)
+	if err != nil {
+		return ErrorInvalidFormat
+	}
+	numOfTerminals := trie.terminal.NumOfBits(true)
+	if tombstones.Size() != numOfTerminals {
+		return ErrorInvalidFormat
+	}
+	trie.tombstones = make([]uint64, (numOfTerminals+uint64(63))/uint64(64))
+	trie.numOfDeleted = 0
+	for rank := uint64(0); rank < numOfTerminals; rank++ {
+		if ok, _ := tombstones.Get(rank); ok {
+			trie.tombstones[rank/uint64(64)] |= uint64(1) << (rank % uint64(64))
+			trie.numOfDeleted++
+		}
+	}
+	return nil
+}
+
+// indexDeletedLexIDs collects lexicographic IDs of deleted keys from tombstones for Rank and Select.
+func (trie *TrieData) indexDeletedLexIDs() {
+	trie.deletedLexIDs = nil
+	if !trie.hasLexID || trie.numOfDeleted == 0 {
+		return
+	}
+	trie.deletedLexIDs = make([]uint64, 0, trie.numOfDeleted)
+	for i, word := range trie.tombstones {
+		for ; word != 0; word &= word - uint64(1) {
+			rank := uint64(i)*uint64(64) + uint64(bits.TrailingZeros64(word))
+			nodeID, _ := trie.terminal.Select1(rank)
+			trie.deletedLexIDs = append(trie.deletedLexIDs, trie.getLexBase(nodeID))
+		}
+	}
+	slices.Sort(trie.deletedLexIDs)
+}
+
+// numOfIDs returns number of IDs assigned to keys of the trie, including deleted keys.
+func numOfIDs(trie Trie) uint64 {
+	switch t := trie.(type) {
+	case *TrieData:
+		return t.terminal.NumOfBits(true)
+	case *DynamicTrie:
+		t.mutex.RLock()
+		defer t.mutex.RUnlock()
+		return t.baseNumOfKeys + uint64(len(t.added))
+	}
+	return trie.GetNumOfKeys()
+}
+
+// containsID returns true if the ID is assigned to a key that isn't deleted.
+func containsID(trie Trie, id uint64) bool {
+	switch t := trie.(type) {
+	case *TrieData:
+		if id >= t.terminal.NumOfBits(true) {
+			return false
+		}
+		return !t.isDeleted(t.rankOfID(id))
+	case *DynamicTrie:
+		t.mutex.RLock()
+		defer t.mutex.RUnlock()
+		return id < t.baseNumOfKeys+uint64(len(t.added)) && !t.isDeleted(id)
+	}
+	_, found := trie.DecodeKey(id)
+	return found
+}
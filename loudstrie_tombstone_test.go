@@ -0,0 +1,92 @@
+package loudstrie
+
+import (
+	"testing"
+)
+
+func TestDelete(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	trie3, _ := NewTrieWithOptions(keyList, TrieOptions{LexicographicID: true})
+	tries := []*Trie{&trie1, &trie2, &trie3}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		ids := make(map[string]uint64)
+		for _, key := range keyList {
+			ids[key], _ = (*trie).ExactMatchSearch(key)
+		}
+		if !trieData.Delete("abc") || trieData.Delete("abc") || trieData.Delete("ab") {
+			t.Error("Delete error")
+		}
+		if !trieData.DeleteID(ids["can"]) || trieData.DeleteID(ids["can"]) || trieData.DeleteID(uint64(len(keyList))) {
+			t.Error("DeleteID error")
+		}
+		check := func(trie Trie) {
+			if trie.GetNumOfKeys() != uint64(len(keyList)-2) {
+				t.Error("Expected", len(keyList)-2, "got", trie.GetNumOfKeys())
+			}
+			for _, key := range keyList {
+				id, found := trie.ExactMatchSearch(key)
+				deleted := key == "abc" || key == "can"
+				if found == deleted {
+					t.Error("ExactMatchSearch error", key)
+				}
+				if !deleted && id != ids[key] {
+					t.Error("Expected", ids[key], "got", id)
+				}
+				if _, found := trie.DecodeKey(ids[key]); found == deleted {
+					t.Error("DecodeKey error", key)
+				}
+			}
+			res := trie.CommonPrefixSearch("abcde", 0)
+			if len(res) != 1 || res[0].ID != ids["abcde"] {
+				t.Error("CommonPrefixSearch error", res)
+			}
+			res2 := trie.PredictiveSearch("ab", 0)
+			if len(res2) != 2 || res2[0] != ids["abcde"] || res2[1] != ids["able"] {
+				t.Error("PredictiveSearch error", res2)
+			}
+		}
+		check(*trie)
+
+		bin, err := (*trie).MarshalBinary()
+		if err != nil {
+			t.Error(err)
+		}
+		newTrie, err := NewTrieFromBinary(bin)
+		if err != nil {
+			t.Error(err)
+		}
+		check(newTrie)
+	}
+
+	m, _ := NewMap(map[string]string{"a": "1", "b": "2"}, false, StringCodec{})
+	m.Trie().(*TrieData).Delete("a")
+	bin, err := m.MarshalBinary()
+	if err != nil {
+		t.Error(err)
+	}
+	m2, err := NewMapFromBinary(bin, StringCodec{})
+	if err != nil {
+		t.Error(err)
+	}
+	if _, found := m2.Get("a"); found {
+		t.Error("Deleted key is found")
+	}
+	if v, _ := m2.Get("b"); v != "2" {
+		t.Error("Expected 2 got", v)
+	}
+
+	trie4, _ := NewTrie([]string{}, false)
+	if trie4.(*TrieData).Delete("") || trie4.(*TrieData).DeleteID(0) {
+		t.Error("Delete error for empty trie.")
+	}
+}
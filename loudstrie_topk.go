@@ -0,0 +1,116 @@
+package loudstrie
+
+import (
+	"container/heap"
+)
+
+/*
+WeightedResult holds result of top-k predictive search.
+*/
+type WeightedResult struct {
+	// ID of the key.
+	ID uint64
+	// Weight of the key.
+	Weight uint64
+}
+
+type topKItem struct {
+	weight uint64
+	seq    uint64
+	isKey  bool
+	id     uint64
+	pos    uint64
+	zeros  uint64
+}
+
+type topKQueue []topKItem
+
+func (q topKQueue) Len() int { return len(q) }
+
+func (q topKQueue) Less(i, j int) bool {
+	if q[i].weight != q[j].weight {
+		return q[i].weight > q[j].weight
+	}
+	if q[i].isKey != q[j].isKey {
+		return q[i].isKey
+	}
+	return q[i].seq < q[j].seq
+}
+
+func (q topKQueue) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q *topKQueue) Push(x interface{}) { *q = append(*q, x.(topKItem)) }
+
+func (q *topKQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
+
+/*
+TopKPredictive searches k keys that have the largest weights in the keys starting with a query string.
+
+This function returns slice of `WeightedResult` in descending order of weight.
+If the trie isn't built by NewTrieWithWeights, weights of all keys are 0.
+*/
+func (trie *TrieData) TopKPredictive(key string, k uint64) []WeightedResult {
+	var res []WeightedResult
+	if k == 0 {
+		return res
+	}
+	pos, zeros, _, found := searchPrefix(trie, key)
+	if !found {
+		return res
+	}
+
+	seq := uint64(0)
+	q := &topKQueue{}
+	heap.Push(q, topKItem{weight: trie.getMaxWeight(pos - zeros), pos: pos, zeros: zeros})
+	for q.Len() > 0 && uint64(len(res)) < k {
+		item := heap.Pop(q).(topKItem)
+		if item.isKey {
+			res = append(res, WeightedResult{item.id, item.weight})
+			continue
+		}
+		ones := item.pos - item.zeros
+		if id, found := trie.terminalID(ones); found {
+			rank, _ := trie.terminal.Rank1(ones)
+			seq++
+			heap.Push(q, topKItem{weight: trie.getWeight(rank), seq: seq, isKey: true, id: id})
+		}
+		for i := uint64(0); !trie.isLeaf(item.pos + i); i++ {
+			nextPos, _ := trie.louds.Select1(item.zeros + i - uint64(1))
+			nextPos++
+			nextZeros := nextPos - item.zeros - i + uint64(1)
+			seq++
+			heap.Push(q, topKItem{weight: trie.getMaxWeight(nextPos - nextZeros), seq: seq, pos: nextPos, zeros: nextZeros})
+		}
+	}
+	return res
+}
+
+func (trie *TrieData) getWeight(rank uint64) uint64 {
+	if !trie.hasWeights {
+		return 0
+	}
+	w, _ := trie.weights.GetBits(trie.weightSize*rank, trie.weightSize)
+	return w
+}
+
+func (trie *TrieData) getMaxWeight(nodeID uint64) uint64 {
+	if !trie.hasWeights {
+		return 0
+	}
+	w, _ := trie.maxWeights.GetBits(trie.weightSize*nodeID, trie.weightSize)
+	return w
+}
+
+// weightOf returns weight of the key of the ID.
+func (trie *TrieData) weightOf(id uint64) uint64 {
+	rank := id
+	if trie.hasLexID {
+		rank, _ = trie.lexRanks.GetBits(trie.lexIDSize*id, trie.lexIDSize)
+	}
+	return trie.getWeight(rank)
+}
@@ -0,0 +1,100 @@
+package loudstrie
+
+import (
+	mrand "math/rand"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestTopKPredictive(t *testing.T) {
+	keyList := []string{
+		"bbc",
+		"able",
+		"abc",
+		"abcde",
+		"can",
+	}
+	weights := []uint64{10, 30, 5, 20, 1}
+
+	trie1, _ := NewTrieWithWeights(keyList, weights, true)
+	trie2, _ := NewTrieWithWeights(keyList, weights, false)
+	bin, _ := trie1.MarshalBinary()
+	trie3, err := NewTrieFromBinary(bin)
+	if err != nil {
+		t.Error(err)
+	}
+	tries := []*Trie{&trie1, &trie2, &trie3}
+
+	for _, trie := range tries {
+		trieData := (*trie).(*TrieData)
+		results := trieData.TopKPredictive("ab", 2)
+		if len(results) != 2 {
+			t.Error(results)
+			continue
+		}
+		key, _ := (*trie).DecodeKey(results[0].ID)
+		if key != "able" || results[0].Weight != 30 {
+			t.Error("Expected able got", key, results[0].Weight)
+		}
+		key, _ = (*trie).DecodeKey(results[1].ID)
+		if key != "abcde" || results[1].Weight != 20 {
+			t.Error("Expected abcde got", key, results[1].Weight)
+		}
+
+		results = trieData.TopKPredictive("", 10)
+		if len(results) != len(keyList) {
+			t.Error(results)
+		}
+		results = trieData.TopKPredictive("abl", 10)
+		if len(results) != 1 || results[0].Weight != 30 {
+			t.Error(results)
+		}
+		results = trieData.TopKPredictive("ablex", 10)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+		results = trieData.TopKPredictive("d", 10)
+		if len(results) != 0 {
+			t.Error(results)
+		}
+	}
+
+	if _, err := NewTrieWithWeights(keyList, weights[1:], false); err != ErrorInvalidWeights {
+		t.Error(err)
+	}
+
+	keyList2 := genKeyList(3000, 8)
+	weights2 := make([]uint64, len(keyList2))
+	expected := make(map[string]uint64)
+	for i, key := range keyList2 {
+		weights2[i] = uint64(mrand.Intn(100000))
+		if w, ok := expected[key]; !ok || w < weights2[i] {
+			expected[key] = weights2[i]
+		}
+	}
+	trie4, _ := NewTrieWithWeights(keyList2, weights2, true)
+	for _, prefix := range []string{"", "a", "B", "0"} {
+		var want []uint64
+		for key, w := range expected {
+			if strings.HasPrefix(key, prefix) {
+				want = append(want, w)
+			}
+		}
+		sort.Slice(want, func(i, j int) bool { return want[i] > want[j] })
+		if len(want) > 20 {
+			want = want[:20]
+		}
+		results := trie4.(*TrieData).TopKPredictive(prefix, 20)
+		if len(results) != len(want) {
+			t.Error("Expected", len(want), "got", len(results))
+			continue
+		}
+		for i, item := range results {
+			key, _ := trie4.DecodeKey(item.ID)
+			if item.Weight != want[i] || expected[key] != item.Weight || !strings.HasPrefix(key, prefix) {
+				t.Error("Expected", want[i], "got", item.Weight, key)
+			}
+		}
+	}
+}
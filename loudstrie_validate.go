@@ -0,0 +1,213 @@
+package loudstrie
+
+import (
+	"fmt"
+	"math/bits"
+)
+
+/*
+Validate verifies invariants between sections of the trie, so that searches on the trie don't panic.
+It is run by UnmarshalBinary, ReadFrom, Load and Mmap unless LoadOptions.SkipValidation is set.
+It visits every node and key, so it takes time proportional to size of the trie.
+
+If the trie is broken, the returned error describes the broken invariant, and errors.Is(err, ErrorInvalidFormat) is true.
+*/
+func (trie *TrieData) Validate() error {
+	if trie.louds == nil || trie.terminal == nil || trie.tail == nil {
+		return invalidf("bit vectors of the tree are missing")
+	}
+	numOfNodes := trie.terminal.Size()
+	if trie.tail.Size() != numOfNodes {
+		return invalidf("size of tail %d doesn't match %d nodes", trie.tail.Size(), numOfNodes)
+	}
+	if numOfKeys := trie.terminal.NumOfBits(true); trie.numOfKeys != numOfKeys {
+		return invalidf("number of keys %d doesn't match %d terminal nodes", trie.numOfKeys, numOfKeys)
+	}
+	if err := trie.validateTree(numOfNodes); err != nil {
+		return err
+	}
+	if err := trie.validateTails(); err != nil {
+		return err
+	}
+	if trie.hasWeights {
+		if err := trie.validateWeights(numOfNodes); err != nil {
+			return err
+		}
+	}
+	if trie.hasLexID {
+		if err := trie.validateLexicographicIDs(numOfNodes); err != nil {
+			return err
+		}
+	}
+	return trie.validateTombstones()
+}
+
+// invalidf returns an error of a broken invariant.
+func invalidf(format string, args ...any) error {
+	return &formatError{"Validate: " + fmt.Sprintf(format, args...)}
+}
+
+/*
+validateTree verifies that louds is a level-order unary degree sequence of numOfNodes nodes, and that labels of children are sorted.
+Each node is closed by 1 after 0s of its children, and a node must be created by 0 of its parent before it is closed.
+*/
+func (trie *TrieData) validateTree(numOfNodes uint64) error {
+	louds := trie.louds
+	first, _ := louds.Get(0)
+	second, _ := louds.Get(1)
+	if louds.Size() < 2 || first || !second {
+		return invalidf("louds doesn't start with the super root")
+	}
+	if numOfNodes == 0 {
+		if louds.Size() != 2 || len(trie.edges) != 0 {
+			return invalidf("empty trie has %d bits of louds and %d edges", louds.Size(), len(trie.edges))
+		}
+		return nil
+	}
+	if louds.Size() != numOfNodes*2+1 || louds.NumOfBits(false) != numOfNodes {
+		return invalidf("louds of %d bits doesn't match %d nodes", louds.Size(), numOfNodes)
+	}
+	if uint64(len(trie.edges)) != numOfNodes-1 {
+		return invalidf("number of edges %d doesn't match %d zero bits of louds", len(trie.edges), numOfNodes)
+	}
+	zeros := uint64(1)
+	ones := uint64(1)
+	numOfChildren := uint64(0)
+	for pos := uint64(2); pos < louds.Size(); pos++ {
+		if bit, _ := louds.Get(pos); !bit {
+			if numOfChildren != 0 && trie.edges[zeros-2] >= trie.edges[zeros-1] {
+				return invalidf("labels of children of node %d aren't sorted", ones-1)
+			}
+			zeros++
+			numOfChildren++
+			continue
+		}
+		// Node ones-1 is closed.
+		nodeID := ones - 1
+		if nodeID >= zeros {
+			return invalidf("node %d is closed before it is created", nodeID)
+		}
+		if hasTail, _ := trie.tail.Get(nodeID); hasTail {
+			if terminal, _ := trie.terminal.Get(nodeID); !terminal || numOfChildren != 0 {
+				return invalidf("node %d that has TAIL isn't a terminal leaf", nodeID)
+			}
+		}
+		ones++
+		numOfChildren = 0
+	}
+	return nil
+}
+
+// validateTails verifies that each node that has TAIL has a TAIL string.
+func (trie *TrieData) validateTails() error {
+	numOfTails := trie.tail.NumOfBits(true)
+	if !trie.hasTailTrie {
+		if uint64(len(trie.vtails)) != numOfTails {
+			return invalidf("number of TAIL strings %d doesn't match %d nodes that have TAIL", len(trie.vtails), numOfTails)
+		}
+		return nil
+	}
+	tailTrie, ok := trie.tailTrie.(*TrieData)
+	if !ok || trie.tailIDs == nil {
+		return invalidf("TAIL trie is missing")
+	}
+	if err := tailTrie.Validate(); err != nil {
+		return err
+	}
+	if tailTrie.numOfDeleted != 0 {
+		return invalidf("TAIL trie has deleted keys")
+	}
+	if trie.tailIDSize > 64 || trie.tailIDs.Size() != numOfTails*trie.tailIDSize {
+		return invalidf("size of TAIL IDs %d doesn't match %d nodes that have TAIL", trie.tailIDs.Size(), numOfTails)
+	}
+	numOfTailKeys := tailTrie.GetNumOfKeys()
+	for i := uint64(0); i < numOfTails; i++ {
+		if id, _ := trie.tailIDs.GetBits(trie.tailIDSize*i, trie.tailIDSize); id >= numOfTailKeys {
+			return invalidf("TAIL ID %d is out of range of %d keys of TAIL trie", id, numOfTailKeys)
+		}
+	}
+	return nil
+}
+
+func (trie *TrieData) validateWeights(numOfNodes uint64) error {
+	if trie.weights == nil || trie.maxWeights == nil {
+		return invalidf("weights are missing")
+	}
+	if trie.weightSize == 0 || trie.weightSize > 64 {
+		return invalidf("size of a weight %d is out of range", trie.weightSize)
+	}
+	if trie.weights.Size() != trie.numOfKeys*trie.weightSize {
+		return invalidf("size of weights %d doesn't match %d keys", trie.weights.Size(), trie.numOfKeys)
+	}
+	if trie.maxWeights.Size() != numOfNodes*trie.weightSize {
+		return invalidf("size of max weights %d doesn't match %d nodes", trie.maxWeights.Size(), numOfNodes)
+	}
+	return nil
+}
+
+/*
+validateLexicographicIDs verifies that lexRanks is a permutation of ranks of the keys,
+and that lexBase of each terminal node is the lexicographic ID of its key.
+*/
+func (trie *TrieData) validateLexicographicIDs(numOfNodes uint64) error {
+	if trie.lexBase == nil || trie.lexRanks == nil {
+		return invalidf("lexicographic IDs are missing")
+	}
+	size := trie.lexIDSize
+	if size == 0 || size > 64 {
+		return invalidf("size of a lexicographic ID %d is out of range", size)
+	}
+	if trie.lexBase.Size() != numOfNodes*size {
+		return invalidf("size of lexicographic bases %d doesn't match %d nodes", trie.lexBase.Size(), numOfNodes)
+	}
+	if trie.lexRanks.Size() != trie.numOfKeys*size {
+		return invalidf("size of lexicographic ranks %d doesn't match %d keys", trie.lexRanks.Size(), trie.numOfKeys)
+	}
+	seen := make([]uint64, (trie.numOfKeys+uint64(63))/uint64(64))
+	for id := uint64(0); id < trie.numOfKeys; id++ {
+		rank, _ := trie.lexRanks.GetBits(size*id, size)
+		if rank >= trie.numOfKeys || seen[rank/uint64(64)]&(uint64(1)<<(rank%uint64(64))) != 0 {
+			return invalidf("lexicographic ranks aren't a permutation of %d keys", trie.numOfKeys)
+		}
+		seen[rank/uint64(64)] |= uint64(1) << (rank % uint64(64))
+	}
+	for nodeID := uint64(0); nodeID < numOfNodes; nodeID++ {
+		base, _ := trie.lexBase.GetBits(size*nodeID, size)
+		if base > trie.numOfKeys {
+			return invalidf("lexicographic base %d of node %d is out of range", base, nodeID)
+		}
+		if terminal, _ := trie.terminal.Get(nodeID); terminal {
+			rank, _ := trie.terminal.Rank1(nodeID)
+			if base == trie.numOfKeys {
+				return invalidf("lexicographic ID %d of node %d is out of range", base, nodeID)
+			}
+			if lexRank, _ := trie.lexRanks.GetBits(size*base, size); lexRank != rank {
+				return invalidf("lexicographic ID %d of node %d doesn't match its rank %d", base, nodeID, rank)
+			}
+		}
+	}
+	return nil
+}
+
+func (trie *TrieData) validateTombstones() error {
+	if trie.tombstones == nil {
+		if trie.numOfDeleted != 0 {
+			return invalidf("tombstones are missing")
+		}
+		return nil
+	}
+	if uint64(len(trie.tombstones)) != (trie.numOfKeys+uint64(63))/uint64(64) {
+		return invalidf("size of tombstones %d doesn't match %d keys", len(trie.tombstones), trie.numOfKeys)
+	}
+	numOfDeleted := uint64(0)
+	for i, word := range trie.tombstones {
+		if i == len(trie.tombstones)-1 && trie.numOfKeys%uint64(64) != 0 && word>>(trie.numOfKeys%uint64(64)) != 0 {
+			return invalidf("tombstones are out of range of %d keys", trie.numOfKeys)
+		}
+		numOfDeleted += uint64(bits.OnesCount64(word))
+	}
+	if numOfDeleted != trie.numOfDeleted {
+		return invalidf("number of deleted keys %d doesn't match %d tombstones", trie.numOfDeleted, numOfDeleted)
+	}
+	return nil
+}
@@ -0,0 +1,168 @@
+package loudstrie
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	keyList := genKeyList(1000, 30)
+	weights := make([]uint64, len(keyList))
+	for i := range weights {
+		weights[i] = uint64(i)
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	trie3, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, Weights: weights, LexicographicID: true})
+	trie3.(*TrieData).Delete(keyList[0])
+	emptyTrie, _ := NewTrie([]string{}, false)
+	for _, trie := range []Trie{trie1, trie2, trie3, emptyTrie} {
+		if err := trie.(*TrieData).Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		trie    Trie
+		corrupt func(trie *TrieData)
+		message string
+	}{
+		{"edges", trie2, func(trie *TrieData) { trie.edges = trie.edges[:len(trie.edges)-1] }, "number of edges"},
+		{"unsorted edges", trie2, func(trie *TrieData) {
+			trie.edges = bytes.Clone(trie.edges)
+			trie.edges[0], trie.edges[1] = trie.edges[1], trie.edges[0]
+		}, "aren't sorted"},
+		{"louds", trie2, func(trie *TrieData) { trie.louds = trie.tail }, "louds"},
+		{"terminal", trie2, func(trie *TrieData) { trie.terminal = trie.tail }, "number of keys"},
+		{"number of keys", trie2, func(trie *TrieData) { trie.numOfKeys++ }, "number of keys"},
+		{"vtails", trie2, func(trie *TrieData) { trie.vtails = trie.vtails[1:] }, "TAIL strings"},
+		{"tail IDs", trie1, func(trie *TrieData) { trie.tailIDSize++ }, "TAIL IDs"},
+		{"weights", trie3, func(trie *TrieData) { trie.weights = trie.maxWeights }, "weights"},
+		{"lexicographic IDs", trie3, func(trie *TrieData) { trie.lexRanks = trie.lexBase }, "lexicographic ranks"},
+		{"tombstones", trie3, func(trie *TrieData) { trie.numOfDeleted++ }, "deleted keys"},
+	}
+	for _, test := range tests {
+		broken := *test.trie.(*TrieData)
+		test.corrupt(&broken)
+		err := broken.Validate()
+		if err == nil || !strings.Contains(err.Error(), test.message) {
+			t.Error(test.name, "Expected error about", test.message, "got", err)
+		}
+		if !errors.Is(err, ErrorInvalidFormat) {
+			t.Error(test.name, "must be ErrorInvalidFormat")
+		}
+	}
+}
+
+func TestSkipValidation(t *testing.T) {
+	keyList := genKeyList(100, 10)
+	trie, _ := NewTrie(keyList, false)
+	broken := *trie.(*TrieData)
+	broken.vtails = broken.vtails[1:]
+	bin, _ := broken.MarshalBinary()
+
+	if _, err := NewTrieFromBinary(bin); err == nil {
+		t.Error("Broken trie must be rejected")
+	}
+	if _, err := new(TrieData).ReadFrom(bytes.NewReader(bin)); err == nil {
+		t.Error("Broken trie must be rejected")
+	}
+	if _, err := NewTrieFromBinaryWithOptions(bin, LoadOptions{SkipValidation: true}); err != nil {
+		t.Error(err)
+	}
+
+	// Checksums of sections aren't verified either.
+	bin, _ = trie.MarshalBinary()
+	bin[bytes.Index(bin, trie.(*TrieData).edges)]++
+	if _, err := NewTrieFromBinary(bin); err != ErrorChecksumMismatch {
+		t.Error("Expected", ErrorChecksumMismatch, "got", err)
+	}
+	if _, err := NewTrieFromBinaryWithOptions(bin, LoadOptions{SkipValidation: true}); err != nil {
+		t.Error(err)
+	}
+}
+
+// exerciseTrie calls searches on the trie, which must not panic if the trie is loaded without errors.
+func exerciseTrie(trie *TrieData) {
+	for id, key := range trie.All() {
+		trie.ExactMatchSearch(key)
+		trie.CommonPrefixSearch(key, 0)
+		trie.DecodeKey(id)
+		trie.ExactMatchSearchBytes([]byte(key))
+		trie.AppendKey(nil, id)
+		if id > 100 {
+			break
+		}
+	}
+	for _, query := range []string{"", "a", "abc", "\xff"} {
+		trie.ExactMatchSearch(query)
+		trie.CommonPrefixSearch(query, 0)
+		trie.PredictiveSearch(query, 10)
+	}
+	for id := uint64(0); id < 10; id++ {
+		trie.DecodeKey(id)
+	}
+}
+
+func addFuzzSeeds(f *testing.F) {
+	keyList := []string{"a", "ab", "abc", "abcde", "able", "bbc", "can", "\xff\xfe"}
+	weights := make([]uint64, len(keyList))
+	for i := range weights {
+		weights[i] = uint64(i)
+	}
+	trie1, _ := NewTrie(keyList, true)
+	trie2, _ := NewTrie(keyList, false)
+	trie3, _ := NewTrieWithOptions(keyList, TrieOptions{UseTailTrie: true, Weights: weights, LexicographicID: true})
+	trie3.(*TrieData).Delete("ab")
+	for _, trie := range []Trie{trie1, trie2, trie3} {
+		bin, _ := trie.MarshalBinary()
+		f.Add(bin)
+		legacy, _ := trie.(*TrieData).MarshalBinaryLegacy()
+		f.Add(legacy)
+	}
+}
+
+func FuzzUnmarshalBinary(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trie, err := NewTrieFromBinary(data)
+		if err != nil {
+			if !errors.Is(err, ErrorInvalidFormat) {
+				t.Error("Unexpected error", err)
+			}
+			return
+		}
+		exerciseTrie(trie.(*TrieData))
+		if _, err := trie.MarshalBinary(); err != nil {
+			t.Error(err)
+		}
+	})
+}
+
+func FuzzReadFrom(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trie := new(TrieData)
+		if _, err := trie.ReadFrom(bytes.NewReader(data)); err != nil {
+			if !errors.Is(err, ErrorInvalidFormat) {
+				t.Error("Unexpected error", err)
+			}
+			return
+		}
+		exerciseTrie(trie)
+	})
+}
+
+func FuzzNewTrieFromBytesNoCopy(f *testing.F) {
+	addFuzzSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		trie, err := NewTrieFromBytesNoCopy(data)
+		if err != nil {
+			return
+		}
+		exerciseTrie(trie.(*TrieData))
+	})
+}